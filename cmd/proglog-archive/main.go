@@ -0,0 +1,162 @@
+// Command proglog-archive exports a range of a log's records to an archive
+// and imports an archive into a fresh log, keeping the records' offsets.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zrma/proglog/internal/log"
+)
+
+func main() {
+	if err := newCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "proglog-archive",
+		Short: "Export a log to an archive and import it into a fresh log",
+		Long: `Export a range of the records of a log dir to an archive, or import an
+archive into a fresh log dir, e.g. to back a log up, move it or seed a test
+environment. An agent keeps its data log in <data-dir>/log and its Raft log
+in <data-dir>/raft/log. The log is opened, so stop the server that has it
+open first.`,
+		SilenceUsage: true,
+	}
+	cmd.AddCommand(newExportCommand(), newImportCommand())
+	return cmd
+}
+
+func newExportCommand() *cobra.Command {
+	var (
+		from, to    uint64
+		file        string
+		compression string
+	)
+	cmd := &cobra.Command{
+		Use:   "export <log-dir>",
+		Short: "Write a range of the log's records to an archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := log.ParseCompression(compression)
+			if err != nil {
+				return err
+			}
+			// NOTE - 세그먼트가 없는 디렉터리를 열면 빈 로그가 생기므로 먼저 확인한다.
+			baseOffsets, err := log.SegmentBaseOffsets(args[0])
+			if err != nil {
+				return err
+			}
+			if len(baseOffsets) == 0 {
+				return fmt.Errorf("no segments in %s", args[0])
+			}
+
+			l, err := log.NewLog(args[0], log.Config{})
+			if err != nil {
+				return err
+			}
+			defer func() { _ = l.Close() }()
+
+			if !cmd.Flags().Changed("from") {
+				if from, err = l.LowestOffset(); err != nil {
+					return err
+				}
+			}
+			if !cmd.Flags().Changed("to") {
+				if to, err = l.HighestOffset(); err != nil {
+					return err
+				}
+			}
+
+			w, closeFile, err := create(cmd, file)
+			if err != nil {
+				return err
+			}
+			if err := l.Export(w, from, to, c); err != nil {
+				return errors.Join(err, closeFile())
+			}
+			if err := closeFile(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "exported %d records at offsets [%d, %d]\n", to-from+1, from, to)
+			return l.Close()
+		},
+	}
+	cmd.Flags().Uint64Var(&from, "from", 0, "First offset to export; defaults to the lowest offset of the log.")
+	cmd.Flags().Uint64Var(&to, "to", 0, "Last offset to export; defaults to the highest offset of the log.")
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "Archive file to write, or - for stdout.")
+	cmd.Flags().StringVar(&compression, "compression", log.CompressionNone.String(), "Compression of the archive: none or gzip.")
+	return cmd
+}
+
+func newImportCommand() *cobra.Command {
+	var file string
+	cmd := &cobra.Command{
+		Use:   "import <log-dir>",
+		Short: "Write the records of an archive into a fresh log",
+		Long: `Write the records of an archive into the log in log-dir, which is created
+if it doesn't exist and must be empty otherwise. The records keep the
+offsets they were exported at.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, closeFile, err := open(cmd, file)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = closeFile() }()
+
+			if err := os.MkdirAll(args[0], 0o755); err != nil {
+				return err
+			}
+			l, err := log.NewLog(args[0], log.Config{})
+			if err != nil {
+				return err
+			}
+			h, err := l.Import(r)
+			if err != nil {
+				return errors.Join(err, l.Close())
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "imported %d records at offsets [%d, %d]\n", h.Count, h.FirstOffset, h.LastOffset())
+			return l.Close()
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "Archive file to read, or - for stdin.")
+	return cmd
+}
+
+// create opens the file to write an archive to; - is the command's output.
+func create(cmd *cobra.Command, file string) (io.Writer, func() error, error) {
+	if file == "-" {
+		return cmd.OutOrStdout(), func() error { return nil }, nil
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() error {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
+
+// open opens the file to read an archive from; - is the command's input.
+func open(cmd *cobra.Command, file string) (io.Reader, func() error, error) {
+	if file == "-" {
+		return cmd.InOrStdin(), func() error { return nil }, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrma/proglog/internal/log"
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+
+	run := func(stdin []byte, args ...string) (string, string, error) {
+		var stdout, stderr strings.Builder
+		cmd := newCommand()
+		cmd.SetArgs(args)
+		cmd.SetIn(bytes.NewReader(stdin))
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&stderr)
+		err := cmd.Execute()
+		return stdout.String(), stderr.String(), err
+	}
+
+	_, _, err := run(nil, "export", src)
+	require.Error(t, err, "없는 디렉터리는 내보낼 수 없다")
+
+	cfg := log.Config{}
+	cfg.Segment.InitialOffset = 1
+	l, err := log.NewLog(t.TempDir(), cfg)
+	require.NoError(t, err)
+	for _, value := range []string{"foo", "bar", "baz"} {
+		_, err := l.Append(&pb.Record{Value: []byte(value)})
+		require.NoError(t, err)
+	}
+	var archive bytes.Buffer
+	require.NoError(t, l.Export(&archive, 1, 3, log.CompressionNone))
+	require.NoError(t, l.Close())
+
+	_, stderr, err := run(archive.Bytes(), "import", src)
+	require.NoError(t, err)
+	require.Equal(t, "imported 3 records at offsets [1, 3]\n", stderr)
+
+	_, _, err = run(archive.Bytes(), "import", src)
+	require.ErrorIs(t, err, log.ErrLogNotEmpty)
+
+	file := filepath.Join(dir, "archive.gz")
+	_, stderr, err = run(nil, "export", src, "--from", "2", "--compression", "gzip", "-f", file)
+	require.NoError(t, err)
+	require.Equal(t, "exported 2 records at offsets [2, 3]\n", stderr)
+
+	dst := filepath.Join(dir, "dst")
+	_, _, err = run(nil, "import", dst, "-f", file)
+	require.NoError(t, err)
+
+	out, _, err := run(nil, "export", dst)
+	require.NoError(t, err)
+	h, _, err := log.ReadArchiveHeader(strings.NewReader(out))
+	require.NoError(t, err)
+	require.Equal(t, log.ArchiveHeader{Compression: log.CompressionNone, FirstOffset: 2, Count: 2}, h)
+
+	l, err = log.NewLog(dst, log.Config{})
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	record, err := l.Read(3)
+	require.NoError(t, err)
+	require.Equal(t, []byte("baz"), record.GetValue(), "오프셋을 유지해서 가져온다")
+
+	_, _, err = run(nil, "export", src, "--compression", "zstd")
+	require.ErrorContains(t, err, `unknown compression "zstd"`)
+}
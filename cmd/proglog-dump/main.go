@@ -0,0 +1,154 @@
+// Command proglog-dump prints what's in the segment files of a log without
+// opening it, and checks that each segment's index agrees with its store.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/raft"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/zrma/proglog/internal/log"
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func main() {
+	if err := newCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// options decide how much dump prints about each segment.
+type options struct {
+	records bool
+	json    bool
+}
+
+func newCommand() *cobra.Command {
+	opts := options{}
+	cmd := &cobra.Command{
+		Use:   "proglog-dump <dir>...",
+		Short: "Print and verify the segments of a log",
+		Long: `Print the segments of each log dir, or of the data log and the Raft log
+of each agent data dir, and verify that their index and store files agree.
+The files are only read, so a log can be dumped even while a server has it
+open, though its latest records may not be written out yet. Exits with
+status 1 if any problem is found.`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var problems int
+			for _, dir := range args {
+				n, err := dumpDir(cmd.OutOrStdout(), dir, opts)
+				if err != nil {
+					return err
+				}
+				problems += n
+			}
+			if problems > 0 {
+				return fmt.Errorf("found %d problems", problems)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&opts.records, "records", "r", false, "Print the offset, term, type, size and position of each record.")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "Print each record decoded as JSON; implies --records.")
+	return cmd
+}
+
+// dumpDir dumps the log in dir or, if dir is an agent's data dir, its data
+// log and Raft log. It returns the number of problems found.
+func dumpDir(w io.Writer, dir string, opts options) (int, error) {
+	dataLog, raftLog := filepath.Join(dir, "log"), filepath.Join(dir, "raft", "log")
+	if isDir(dataLog) && isDir(raftLog) {
+		n, err := dumpLog(w, dataLog, false, opts)
+		if err != nil {
+			return n, err
+		}
+		m, err := dumpLog(w, raftLog, true, opts)
+		return n + m, err
+	}
+	return dumpLog(w, dir, filepath.Base(filepath.Dir(dir)) == "raft", opts)
+}
+
+// dumpLog dumps each segment of the log in dir; the records of a Raft log
+// have their type printed as a raft.LogType.
+func dumpLog(w io.Writer, dir string, isRaft bool, opts options) (int, error) {
+	baseOffsets, err := log.SegmentBaseOffsets(dir)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(w, "== %s: %d segments\n", dir, len(baseOffsets))
+
+	var problems int
+	var next uint64
+	for i, base := range baseOffsets {
+		r, err := log.InspectSegment(dir, base)
+		if err != nil {
+			return problems, err
+		}
+		// NOTE - 세그먼트는 앞 세그먼트가 끝난 오프셋에서 시작해야 한다.
+		if i > 0 && base != next {
+			r.Problems = append(r.Problems, fmt.Sprintf("segment starts at offset %d but the previous one ends at %d", base, next))
+		}
+		next = r.NextOffset()
+
+		if err := dumpSegment(w, r, isRaft, opts); err != nil {
+			return problems, err
+		}
+		problems += len(r.Problems)
+	}
+	return problems, nil
+}
+
+func dumpSegment(w io.Writer, r *log.SegmentReport, isRaft bool, opts options) error {
+	fmt.Fprintf(w, "segment %d: offsets [%d, %d), %d records, store %d bytes, index %d bytes\n",
+		r.BaseOffset, r.BaseOffset, r.NextOffset(), len(r.Records), r.StoreSize, r.IndexSize)
+
+	if opts.records || opts.json {
+		for _, rec := range r.Records {
+			if err := dumpRecord(w, rec, isRaft, opts); err != nil {
+				return err
+			}
+		}
+	}
+	for _, problem := range r.Problems {
+		fmt.Fprintf(w, "  problem: %s\n", problem)
+	}
+	return nil
+}
+
+func dumpRecord(w io.Writer, rec log.RecordReport, isRaft bool, opts options) error {
+	record := rec.Record
+	if record == nil {
+		_, err := fmt.Fprintf(w, "  position %d: %d bytes that don't decode\n", rec.Position, rec.Size)
+		return err
+	}
+	if opts.json {
+		b, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "  %s\n", b)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "  offset %d term %d type %s size %d position %d\n",
+		record.GetOffset(), record.GetTerm(), recordType(record, isRaft), rec.Size, rec.Position)
+	return err
+}
+
+func recordType(record *pb.Record, isRaft bool) string {
+	if isRaft {
+		return raft.LogType(record.GetType()).String()
+	}
+	return fmt.Sprint(record.GetType())
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrma/proglog/internal/log"
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestDump(t *testing.T) {
+	dir := t.TempDir()
+	dataLog, raftLog := filepath.Join(dir, "log"), filepath.Join(dir, "raft", "log")
+	require.NoError(t, os.MkdirAll(dataLog, 0o755))
+	require.NoError(t, os.MkdirAll(raftLog, 0o755))
+
+	cfg := log.Config{}
+	cfg.Segment.MaxIndexBytes = 12 * 2
+	l, err := log.NewLog(dataLog, cfg)
+	require.NoError(t, err)
+	for _, value := range []string{"foo", "bar", "baz"} {
+		_, err := l.Append(&pb.Record{Value: []byte(value), Term: 2})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	cfg.Segment.InitialOffset = 1
+	l, err = log.NewLog(raftLog, cfg)
+	require.NoError(t, err)
+	_, err = l.Append(&pb.Record{Term: 1, Type: 1})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	dump := func(args ...string) (string, error) {
+		var out strings.Builder
+		cmd := newCommand()
+		cmd.SetArgs(args)
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := dump("--records", dir)
+	require.NoError(t, err)
+	require.Contains(t, out, "== "+dataLog+": 2 segments\n")
+	require.Contains(t, out, "segment 0: offsets [0, 2), 2 records, store 32 bytes, index 24 bytes\n")
+	require.Contains(t, out, "  offset 2 term 2 type 0 size 9 position 0\n")
+	require.Contains(t, out, "== "+raftLog+": 1 segments\n")
+	require.Contains(t, out, "  offset 1 term 1 type LogNoop size 6 position 0\n", "Raft 로그는 레코드 타입을 이름으로 보여 준다")
+
+	out, err = dump("--json", raftLog)
+	require.NoError(t, err)
+	require.Contains(t, out, `"type":1`)
+
+	require.NoError(t, os.Truncate(filepath.Join(dataLog, "2.store"), 3))
+	out, err = dump(dir)
+	require.EqualError(t, err, "found 2 problems")
+	require.Contains(t, out, "  problem: store ends with 3 bytes at position 0, too few for a length\n")
+	require.Contains(t, out, "  problem: 1 index entries from offset 2 point past the end of the store\n")
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/zrma/proglog/internal/agent"
+	tlsconfig "github.com/zrma/proglog/internal/config"
+)
+
+// envPrefix prefixes the environment variable of each flag, e.g.
+// PROGLOG_DATA_DIR sets --data-dir.
+const envPrefix = "PROGLOG"
+
+// setupFlags defines the flags of the proglog command. A config file and the
+// environment can set each of them too, under the flag's name.
+func setupFlags(flags *pflag.FlagSet) {
+	hostname, _ := os.Hostname()
+
+	flags.String("config-file", "", "YAML or TOML file to read the flags from.")
+
+	flags.String("data-dir", filepath.Join(os.TempDir(), "proglog"), "Directory to store log and Raft data in.")
+	flags.String("node-name", hostname, "Unique server ID.")
+
+	flags.String("bind-addr", "127.0.0.1:8401", "Address to bind Serf on.")
+	flags.Int("rpc-port", 8400, "Port for RPC clients (and Raft) connections.")
+	flags.StringSlice("start-join-addrs", nil, "Serf addresses to join.")
+	flags.Bool("bootstrap", false, "Bootstrap the cluster.")
+	flags.Bool("non-voter", false, "Join the cluster as a read replica that doesn't vote.")
+	flags.Bool("raft-over-grpc", false, "Carry Raft traffic as a gRPC service on the RPC port.")
+	flags.Bool("pull-replication", false, "Replicate by consuming each peer's log instead of through Raft; every node accepts writes.")
+
+	flags.String("acl-model-file", "", "Path to ACL model.")
+	flags.String("acl-policy-file", "", "Path to ACL policy.")
+
+	flags.String("server-tls-cert-file", "", "Path to server tls cert.")
+	flags.String("server-tls-key-file", "", "Path to server tls key.")
+	flags.String("server-tls-ca-file", "", "Path to server certificate authority.")
+
+	flags.String("peer-tls-cert-file", "", "Path to peer tls cert.")
+	flags.String("peer-tls-key-file", "", "Path to peer tls key.")
+	flags.String("peer-tls-ca-file", "", "Path to peer certificate authority.")
+	flags.String("peer-tls-server-name", "", "Name to verify peers' certificates against; defaults to each peer's host.")
+
+	flags.String("mirror-source-addr", "", "RPC address of a server of another cluster to mirror the log of; disabled if empty.")
+	flags.String("mirror-tls-cert-file", "", "Path to the tls cert to present to the mirrored cluster.")
+	flags.String("mirror-tls-key-file", "", "Path to the tls key to present to the mirrored cluster.")
+	flags.String("mirror-tls-ca-file", "", "Path to the certificate authority of the mirrored cluster.")
+	flags.String("mirror-checkpoint-file", "", "File to keep the next offset to mirror in; defaults to mirror-checkpoint in data-dir.")
+	flags.Bool("mirror-skip-gaps", false, "Skip ahead when the mirrored cluster has removed records not yet mirrored, instead of stopping.")
+
+	flags.String("http-addr", "", "Address to serve the log over HTTP on; disabled if empty.")
+	flags.String("metrics-addr", "", "Address to serve Prometheus metrics on; disabled if empty.")
+	flags.String("probe-addr", "", "Address to serve /healthz, /readyz and /debug/pprof on; disabled if empty.")
+
+	flags.String("log-level", "info", "Log level: debug, info, warn or error.")
+	flags.String("log-encoding", "console", "Log encoding: console or json.")
+}
+
+// newViper reads the flags, overridden by the environment, overriding the
+// config file, overriding the defaults.
+func newViper(flags *pflag.FlagSet) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+	if err := v.BindPFlags(flags); err != nil {
+		return nil, err
+	}
+
+	if file := v.GetString("config-file"); file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+	}
+	return v, nil
+}
+
+// tlsFiles are the files a tls.Config is loaded from, and the name the
+// other side's certificate is verified against.
+type tlsFiles struct {
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	ServerName string
+}
+
+// config is what the proglog command runs an agent with.
+type config struct {
+	agent.Config
+	ServerTLS tlsFiles
+	PeerTLS   tlsFiles
+	MirrorTLS tlsFiles
+}
+
+// loadConfig reads config from v and validates it, joining every problem
+// found into the error, so a bad config is fixed in one go.
+func loadConfig(v *viper.Viper) (config, error) {
+	var c config
+	c.DataDir = v.GetString("data-dir")
+	c.NodeName = v.GetString("node-name")
+	c.BindAddr = v.GetString("bind-addr")
+	c.RPCPort = v.GetInt("rpc-port")
+	c.StartJoinPeers = splitList(v.GetStringSlice("start-join-addrs"))
+	c.Bootstrap = v.GetBool("bootstrap")
+	c.NonVoter = v.GetBool("non-voter")
+	c.RaftOverGRPC = v.GetBool("raft-over-grpc")
+	c.PullReplication = v.GetBool("pull-replication")
+	c.ACLModelFile = v.GetString("acl-model-file")
+	c.ACLPolicyFile = v.GetString("acl-policy-file")
+	c.ServerTLS = tlsFiles{
+		CertFile: v.GetString("server-tls-cert-file"),
+		KeyFile:  v.GetString("server-tls-key-file"),
+		CAFile:   v.GetString("server-tls-ca-file"),
+	}
+	c.PeerTLS = tlsFiles{
+		CertFile:   v.GetString("peer-tls-cert-file"),
+		KeyFile:    v.GetString("peer-tls-key-file"),
+		CAFile:     v.GetString("peer-tls-ca-file"),
+		ServerName: v.GetString("peer-tls-server-name"),
+	}
+	c.MirrorSourceAddr = v.GetString("mirror-source-addr")
+	c.MirrorTLS = tlsFiles{
+		CertFile: v.GetString("mirror-tls-cert-file"),
+		KeyFile:  v.GetString("mirror-tls-key-file"),
+		CAFile:   v.GetString("mirror-tls-ca-file"),
+	}
+	c.MirrorCheckpointFile = v.GetString("mirror-checkpoint-file")
+	c.MirrorSkipGaps = v.GetBool("mirror-skip-gaps")
+	c.HTTPAddr = v.GetString("http-addr")
+	c.MetricsAddr = v.GetString("metrics-addr")
+	c.ProbeAddr = v.GetString("probe-addr")
+	c.LogLevel = v.GetString("log-level")
+	c.LogEncoding = v.GetString("log-encoding")
+
+	if err := c.validate(); err != nil {
+		return config{}, err
+	}
+	return c, nil
+}
+
+func (c config) validate() error {
+	var errs []error
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("data-dir is required"))
+	}
+	if c.NodeName == "" {
+		errs = append(errs, errors.New("node-name is required"))
+	}
+	if _, _, err := net.SplitHostPort(c.BindAddr); err != nil {
+		errs = append(errs, fmt.Errorf("bind-addr %q isn't host:port: %w", c.BindAddr, err))
+	}
+	if c.RPCPort < 1 || c.RPCPort > 65535 {
+		errs = append(errs, fmt.Errorf("rpc-port %d isn't between 1 and 65535", c.RPCPort))
+	}
+	if c.Bootstrap && len(c.StartJoinPeers) > 0 {
+		errs = append(errs, errors.New("bootstrap and start-join-addrs are exclusive: a node either starts a cluster or joins one"))
+	}
+	if c.Bootstrap && c.NonVoter {
+		errs = append(errs, errors.New("bootstrap and non-voter are exclusive: the bootstrapping node must vote"))
+	}
+	if c.PullReplication && (c.Bootstrap || c.NonVoter || c.RaftOverGRPC) {
+		errs = append(errs, errors.New("pull-replication doesn't use Raft, so bootstrap, non-voter and raft-over-grpc don't apply"))
+	}
+	if c.ACLModelFile == "" || c.ACLPolicyFile == "" {
+		errs = append(errs, errors.New("acl-model-file and acl-policy-file are required"))
+	}
+	for name, file := range map[string]string{
+		"acl-model-file":       c.ACLModelFile,
+		"acl-policy-file":      c.ACLPolicyFile,
+		"server-tls-cert-file": c.ServerTLS.CertFile,
+		"server-tls-key-file":  c.ServerTLS.KeyFile,
+		"server-tls-ca-file":   c.ServerTLS.CAFile,
+		"peer-tls-cert-file":   c.PeerTLS.CertFile,
+		"peer-tls-key-file":    c.PeerTLS.KeyFile,
+		"peer-tls-ca-file":     c.PeerTLS.CAFile,
+		"mirror-tls-cert-file": c.MirrorTLS.CertFile,
+		"mirror-tls-key-file":  c.MirrorTLS.KeyFile,
+		"mirror-tls-ca-file":   c.MirrorTLS.CAFile,
+	} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if (c.ServerTLS.CertFile == "") != (c.ServerTLS.KeyFile == "") {
+		errs = append(errs, errors.New("server-tls-cert-file and server-tls-key-file go together"))
+	}
+	if c.ServerTLS.CertFile != "" && c.ServerTLS.CAFile == "" {
+		errs = append(errs, errors.New("server-tls-ca-file is required with server-tls-cert-file: clients are authorized by their certificates"))
+	}
+	if (c.PeerTLS.CertFile == "") != (c.PeerTLS.KeyFile == "") {
+		errs = append(errs, errors.New("peer-tls-cert-file and peer-tls-key-file go together"))
+	}
+	if c.MirrorSourceAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MirrorSourceAddr); err != nil {
+			errs = append(errs, fmt.Errorf("mirror-source-addr %q isn't host:port: %w", c.MirrorSourceAddr, err))
+		}
+	} else if c.MirrorTLS != (tlsFiles{}) || c.MirrorCheckpointFile != "" || c.MirrorSkipGaps {
+		errs = append(errs, errors.New("mirror-tls-*, mirror-checkpoint-file and mirror-skip-gaps need mirror-source-addr"))
+	}
+	if (c.MirrorTLS.CertFile == "") != (c.MirrorTLS.KeyFile == "") {
+		errs = append(errs, errors.New("mirror-tls-cert-file and mirror-tls-key-file go together"))
+	}
+	switch c.LogEncoding {
+	case "console", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log-encoding %q isn't console or json", c.LogEncoding))
+	}
+	return errors.Join(errs...)
+}
+
+// agentConfig returns the agent.Config of c, with its TLS files loaded.
+func (c config) agentConfig() (agent.Config, error) {
+	cfg := c.Config
+	var err error
+	if c.ServerTLS.CertFile != "" {
+		cfg.ServerTLSConfig, err = c.ServerTLS.tlsConfig(true)
+		if err != nil {
+			return agent.Config{}, fmt.Errorf("server TLS: %w", err)
+		}
+	}
+	if c.PeerTLS.CertFile != "" {
+		cfg.PeerTLSConfig, err = c.PeerTLS.tlsConfig(false)
+		if err != nil {
+			return agent.Config{}, fmt.Errorf("peer TLS: %w", err)
+		}
+	}
+	if c.MirrorTLS.CertFile != "" {
+		cfg.MirrorTLSConfig, err = c.MirrorTLS.tlsConfig(false)
+		if err != nil {
+			return agent.Config{}, fmt.Errorf("mirror TLS: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// tlsConfig loads the files as a server, which requires clients to present a
+// certificate the CA signed, or as a client, which verifies the server's
+// certificate with the CA if one is given.
+func (files tlsFiles) tlsConfig(server bool) (*tls.Config, error) {
+	return tlsconfig.SetupTLSConfig(tlsconfig.TLSConfig{
+		CertFile:      files.CertFile,
+		KeyFile:       files.KeyFile,
+		CAFile:        files.CAFile,
+		ServerAddress: files.ServerName,
+		Server:        server,
+	})
+}
+
+// splitList also splits the items of list at commas, since viper only splits
+// a list set by the environment or a config string at spaces.
+func splitList(list []string) []string {
+	var items []string
+	for _, s := range list {
+		for item := range strings.SplitSeq(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	model := filepath.Join(dir, "model.conf")
+	policy := filepath.Join(dir, "policy.csv")
+	for _, file := range []string{model, policy} {
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+	}
+
+	t.Run("OK/Precedence", func(t *testing.T) {
+		configFile := filepath.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(configFile, []byte(`
+node-name: from-file
+rpc-port: 9000
+bind-addr: 127.0.0.1:9001
+acl-model-file: `+model+`
+acl-policy-file: `+policy+`
+start-join-addrs:
+  - 127.0.0.1:9101
+  - 127.0.0.1:9201
+`), 0o600))
+		t.Setenv("PROGLOG_RPC_PORT", "9100")
+		t.Setenv("PROGLOG_NODE_NAME", "from-env")
+
+		cfg, err := load(t, "--config-file", configFile, "--node-name", "from-flag")
+		require.NoError(t, err)
+		require.Equal(t, "from-flag", cfg.NodeName, "플래그가 환경 변수보다 우선한다")
+		require.Equal(t, 9100, cfg.RPCPort, "환경 변수가 설정 파일보다 우선한다")
+		require.Equal(t, "127.0.0.1:9001", cfg.BindAddr, "설정 파일이 기본값보다 우선한다")
+		require.Equal(t, []string{"127.0.0.1:9101", "127.0.0.1:9201"}, cfg.StartJoinPeers)
+		require.Equal(t, "info", cfg.LogLevel)
+	})
+
+	t.Run("OK/TOML", func(t *testing.T) {
+		configFile := filepath.Join(dir, "config.toml")
+		require.NoError(t, os.WriteFile(configFile, []byte(`
+node-name = "from-toml"
+bootstrap = true
+acl-model-file = "`+model+`"
+acl-policy-file = "`+policy+`"
+`), 0o600))
+
+		cfg, err := load(t, "--config-file", configFile)
+		require.NoError(t, err)
+		require.Equal(t, "from-toml", cfg.NodeName)
+		require.True(t, cfg.Bootstrap)
+	})
+
+	t.Run("OK/Mirror", func(t *testing.T) {
+		t.Setenv("PROGLOG_MIRROR_SOURCE_ADDR", "primary.example.com:8400")
+
+		cfg, err := load(t, "--acl-model-file", model, "--acl-policy-file", policy, "--mirror-checkpoint-file", filepath.Join(dir, "checkpoint"), "--mirror-skip-gaps")
+		require.NoError(t, err)
+		require.Equal(t, "primary.example.com:8400", cfg.MirrorSourceAddr)
+		require.Equal(t, filepath.Join(dir, "checkpoint"), cfg.MirrorCheckpointFile)
+		require.True(t, cfg.MirrorSkipGaps)
+	})
+
+	t.Run("OK/EnvList", func(t *testing.T) {
+		t.Setenv("PROGLOG_START_JOIN_ADDRS", "127.0.0.1:9101,127.0.0.1:9201")
+
+		cfg, err := load(t, "--acl-model-file", model, "--acl-policy-file", policy)
+		require.NoError(t, err)
+		require.Equal(t, []string{"127.0.0.1:9101", "127.0.0.1:9201"}, cfg.StartJoinPeers, "쉼표로 구분한 목록도 나눈다")
+	})
+
+	t.Run("Err/Invalid", func(t *testing.T) {
+		_, err := load(t,
+			"--node-name", "",
+			"--bind-addr", "127.0.0.1",
+			"--rpc-port", "70000",
+			"--bootstrap",
+			"--start-join-addrs", "127.0.0.1:9101",
+			"--pull-replication",
+			"--acl-model-file", filepath.Join(dir, "missing.conf"),
+			"--server-tls-cert-file", model,
+			"--mirror-tls-key-file", model,
+			"--log-encoding", "xml",
+		)
+		require.Error(t, err)
+		for _, want := range []string{
+			"node-name is required",
+			`bind-addr "127.0.0.1" isn't host:port`,
+			"rpc-port 70000 isn't between 1 and 65535",
+			"bootstrap and start-join-addrs are exclusive",
+			"pull-replication doesn't use Raft",
+			"acl-model-file and acl-policy-file are required",
+			"acl-model-file: stat",
+			"server-tls-cert-file and server-tls-key-file go together",
+			"server-tls-ca-file is required with server-tls-cert-file",
+			"mirror-tls-*, mirror-checkpoint-file and mirror-skip-gaps need mirror-source-addr",
+			"mirror-tls-cert-file and mirror-tls-key-file go together",
+			`log-encoding "xml" isn't console or json`,
+		} {
+			require.ErrorContains(t, err, want, "잘못된 설정을 모두 알린다")
+		}
+	})
+
+	t.Run("Err/MissingConfigFile", func(t *testing.T) {
+		_, err := load(t, "--config-file", filepath.Join(dir, "missing.yaml"))
+		require.ErrorContains(t, err, "read config file")
+	})
+}
+
+func load(t *testing.T, args ...string) (config, error) {
+	t.Helper()
+
+	flags := pflag.NewFlagSet("proglog", pflag.ContinueOnError)
+	setupFlags(flags)
+	require.NoError(t, flags.Parse(args))
+
+	v, err := newViper(flags)
+	if err != nil {
+		return config{}, err
+	}
+	return loadConfig(v)
+}
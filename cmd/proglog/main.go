@@ -0,0 +1,67 @@
+// Command proglog runs a node of a proglog cluster, an agent.Agent
+// configured by flags, PROGLOG_* environment variables and a YAML or TOML
+// config file, in that order of precedence.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zrma/proglog/internal/agent"
+)
+
+func main() {
+	if err := newCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "proglog",
+		Short: "Run a proglog server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			v, err := newViper(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			cfg, err := loadConfig(v)
+			if err != nil {
+				return err
+			}
+			// NOTE - 설정이 올바르면 이후 에러에는 사용법을 출력하지 않는다.
+			cmd.SilenceUsage = true
+			return run(cmd.Context(), cfg)
+		},
+	}
+	setupFlags(cmd.Flags())
+	return cmd
+}
+
+// run runs an agent until ctx is done or it gets SIGINT or SIGTERM, then
+// shuts it down gracefully.
+func run(ctx context.Context, cfg config) error {
+	agentConfig, err := cfg.agentConfig()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(agentConfig.DataDir, 0o755); err != nil {
+		return err
+	}
+
+	a, err := agent.New(agentConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	return a.Shutdown()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/travisjeffery/go-dynaport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	tlsconfig "github.com/zrma/proglog/internal/config"
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestRun_PeerTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// NOTE - 기본 스트림 레이어로 Raft를 주고받도록 raft-over-grpc 없이 두 노드를 띄운다.
+	ports := dynaport.Get(4)
+	bindAddr := func(i int) string { return fmt.Sprintf("127.0.0.1:%d", ports[2*i]) }
+	rpcAddr := func(i int) string { return fmt.Sprintf("127.0.0.1:%d", ports[2*i+1]) }
+
+	var done []chan error
+	start := func(i int) {
+		args := []string{
+			"--data-dir", t.TempDir(),
+			"--node-name", fmt.Sprintf("node-%d", i),
+			"--bind-addr", bindAddr(i),
+			"--rpc-port", fmt.Sprint(ports[2*i+1]),
+			"--acl-model-file", tlsconfig.ACLModelFile,
+			"--acl-policy-file", tlsconfig.ACLPolicyFile,
+			"--server-tls-cert-file", tlsconfig.ServerCertFile,
+			"--server-tls-key-file", tlsconfig.ServerKeyFile,
+			"--server-tls-ca-file", tlsconfig.CAFile,
+			"--peer-tls-cert-file", tlsconfig.RootClientCertFile,
+			"--peer-tls-key-file", tlsconfig.RootClientKeyFile,
+			"--peer-tls-ca-file", tlsconfig.CAFile,
+		}
+		if i == 0 {
+			args = append(args, "--bootstrap")
+		} else {
+			args = append(args, "--start-join-addrs", bindAddr(0))
+		}
+		cfg, err := load(t, args...)
+		require.NoError(t, err)
+
+		errc := make(chan error, 1)
+		go func() { errc <- run(ctx, cfg) }()
+		done = append(done, errc)
+	}
+
+	clientTLSConfig, err := tlsconfig.SetupTLSConfig(tlsconfig.TLSConfig{
+		CertFile: tlsconfig.RootClientCertFile,
+		KeyFile:  tlsconfig.RootClientKeyFile,
+		CAFile:   tlsconfig.CAFile,
+	})
+	require.NoError(t, err)
+	client := func(i int) pb.LogClient {
+		conn, err := grpc.NewClient(rpcAddr(i), grpc.WithTransportCredentials(credentials.NewTLS(clientTLSConfig)))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return pb.NewLogClient(conn)
+	}
+
+	// NOTE - 부트스트랩한 노드가 리더가 된 뒤에 다른 노드가 조인한다.
+	start(0)
+	leader := client(0)
+	var produce *pb.ProduceResponse
+	require.Eventually(t, func() bool {
+		produce, err = leader.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("foo")}})
+		return err == nil
+	}, 10*time.Second, 50*time.Millisecond)
+
+	start(1)
+	follower := client(1)
+	require.Eventually(t, func() bool {
+		consume, err := follower.Consume(ctx, &pb.ConsumeRequest{Offset: produce.GetOffset()})
+		return err == nil && string(consume.GetRecord().GetValue()) == "foo"
+	}, 10*time.Second, 50*time.Millisecond, "피어 TLS로 Raft를 주고받아 팔로워에 복제한다")
+
+	cancel()
+	for _, errc := range done {
+		require.NoError(t, <-errc)
+	}
+}
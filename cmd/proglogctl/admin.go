@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func newServersCommand(c *client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "servers",
+		Short: "List the servers of the cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := c.call(cmd.Context())
+			defer cancel()
+			res, err := c.admin.GetServers(ctx, &pb.GetServersRequest{})
+			if err != nil {
+				return err
+			}
+			return c.out.message(res, func(w io.Writer) error {
+				return table(w, []string{"ID", "RPC ADDR", "LEADER", "VOTER"}, res.GetServers(), func(s *pb.Server) []string {
+					return []string{s.GetId(), s.GetRpcAddr(), strconv.FormatBool(s.GetIsLeader()), strconv.FormatBool(!s.GetNonvoter())}
+				})
+			})
+		},
+	}
+}
+
+func newAdminCommand(c *client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Change the membership and telemetry of the cluster",
+	}
+	cmd.AddCommand(
+		newJoinCommand(c),
+		newLeaveCommand(c),
+		newTransferLeadershipCommand(c),
+		newLagCommand(c),
+		newTelemetryCommand(c),
+	)
+	return cmd
+}
+
+func newJoinCommand(c *client) *cobra.Command {
+	var nonvoter bool
+	cmd := &cobra.Command{
+		Use:   "join <id> <rpc-addr>",
+		Short: "Add a server to the cluster",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := c.call(cmd.Context())
+			defer cancel()
+			_, err := c.admin.Join(ctx, &pb.JoinRequest{Id: args[0], RpcAddr: args[1], Nonvoter: nonvoter})
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&nonvoter, "nonvoter", false, "Join as a read replica that doesn't vote.")
+	return cmd
+}
+
+func newLeaveCommand(c *client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "leave <id>",
+		Short: "Remove a server from the cluster",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := c.call(cmd.Context())
+			defer cancel()
+			_, err := c.admin.Leave(ctx, &pb.LeaveRequest{Id: args[0]})
+			return err
+		},
+	}
+}
+
+func newTransferLeadershipCommand(c *client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "transfer-leadership [id]",
+		Short: "Hand leadership to a server, by default the most up-to-date voter",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &pb.TransferLeadershipRequest{}
+			if len(args) == 1 {
+				req.Id = args[0]
+			}
+			ctx, cancel := c.call(cmd.Context())
+			defer cancel()
+			_, err := c.admin.TransferLeadership(ctx, req)
+			return err
+		},
+	}
+}
+
+func newLagCommand(c *client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lag",
+		Short: "Print how many entries each replica is behind",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel := c.call(cmd.Context())
+			defer cancel()
+			res, err := c.admin.GetReplicationLag(ctx, &pb.GetReplicationLagRequest{})
+			if err != nil {
+				return err
+			}
+			return c.out.message(res, func(w io.Writer) error {
+				return table(w, []string{"PEER", "KIND", "LAG"}, res.GetLags(), func(l *pb.ReplicationLag) []string {
+					return []string{l.GetPeer(), l.GetKind(), strconv.FormatUint(l.GetLag(), 10)}
+				})
+			})
+		},
+	}
+}
+
+func newTelemetryCommand(c *client) *cobra.Command {
+	var (
+		logLevel     string
+		ratio        float64
+		methodRatios map[string]string
+		sampleErrors bool
+	)
+	cmd := &cobra.Command{
+		Use:   "telemetry",
+		Short: "Print, or change with the flags, the log level and trace sampling of the server called",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			flags := cmd.Flags()
+			telemetry := &pb.Telemetry{LogLevel: logLevel}
+			// NOTE - 샘플링 설정은 통째로 바뀌므로 하나라도 주어지면 모두 보낸다.
+			if flags.Changed("sampling-ratio") || flags.Changed("method-ratio") || flags.Changed("sample-errors") {
+				telemetry.Sampling = &pb.TraceSampling{
+					Ratio:        ratio,
+					MethodRatios: make(map[string]float64, len(methodRatios)),
+					SampleErrors: sampleErrors,
+				}
+				for method, s := range methodRatios {
+					r, err := strconv.ParseFloat(s, 64)
+					if err != nil {
+						return fmt.Errorf("invalid ratio of %s: %w", method, err)
+					}
+					telemetry.Sampling.MethodRatios[method] = r
+				}
+			}
+
+			ctx, cancel := c.call(cmd.Context())
+			defer cancel()
+			var got *pb.Telemetry
+			if telemetry.GetLogLevel() == "" && telemetry.GetSampling() == nil {
+				res, err := c.admin.GetTelemetry(ctx, &pb.GetTelemetryRequest{})
+				if err != nil {
+					return err
+				}
+				got = res.GetTelemetry()
+			} else {
+				res, err := c.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: telemetry})
+				if err != nil {
+					return err
+				}
+				got = res.GetTelemetry()
+			}
+			return c.out.message(got, func(w io.Writer) error {
+				return printTelemetry(w, got)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&logLevel, "log-level", "", "Log level to set: debug, info, warn or error.")
+	cmd.Flags().Float64Var(&ratio, "sampling-ratio", 0, "Share of traces to sample, from 0 to 1.")
+	cmd.Flags().StringToStringVar(&methodRatios, "method-ratio", nil, "Sampling ratio of the traces starting at a span as name=ratio; repeatable.")
+	cmd.Flags().BoolVar(&sampleErrors, "sample-errors", false, "Export the spans ending in an error even if unsampled.")
+	return cmd
+}
+
+func printTelemetry(w io.Writer, t *pb.Telemetry) error {
+	if _, err := fmt.Fprintf(w, "log level:      %s\n", t.GetLogLevel()); err != nil {
+		return err
+	}
+	sampling := t.GetSampling()
+	if sampling == nil {
+		return nil
+	}
+	var methods []string
+	for _, method := range slices.Sorted(maps.Keys(sampling.GetMethodRatios())) {
+		methods = append(methods, fmt.Sprintf("%s=%g", method, sampling.GetMethodRatios()[method]))
+	}
+	_, err := fmt.Fprintf(w, "sampling ratio: %g\nmethod ratios:  %s\nsample errors:  %t\n",
+		sampling.GetRatio(), strings.Join(methods, ","), sampling.GetSampleErrors())
+	return err
+}
+
+// table writes a row of columns per item under the header, aligned.
+func table[T any](w io.Writer, header []string, items []T, columns func(T) []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if _, err := fmt.Fprintln(tw, strings.Join(columns(item), "\t")); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func newConsumeCommand(c *client) *cobra.Command {
+	var (
+		from, to uint64
+		follow   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "consume [offset]",
+		Short: "Print the record at offset, the records from --from to --to, or follow the log",
+		Long: `Print the record at offset, or the records from --from, by default the
+lowest offset, up to --to, by default the high watermark, exclusive. With
+--follow, print the records from --from on as they're appended until
+interrupted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			if len(args) == 1 {
+				if flags.Changed("from") || flags.Changed("to") || follow {
+					return errors.New("an offset excludes --from, --to and --follow")
+				}
+				offset, err := strconv.ParseUint(args[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid offset %q: %w", args[0], err)
+				}
+				return c.consumeRange(cmd, offset, offset+1)
+			}
+			if follow && flags.Changed("to") {
+				return errors.New("--follow excludes --to")
+			}
+
+			if !flags.Changed("from") || !flags.Changed("to") {
+				offsets, err := c.offsets(cmd)
+				if err != nil {
+					return err
+				}
+				if !flags.Changed("from") {
+					from = offsets.GetLowestOffset()
+				}
+				if !flags.Changed("to") {
+					to = offsets.GetHighWatermark()
+				}
+			}
+			if follow {
+				return c.follow(cmd, from)
+			}
+			return c.consumeRange(cmd, from, to)
+		},
+	}
+	cmd.Flags().Uint64Var(&from, "from", 0, "First offset to print.")
+	cmd.Flags().Uint64Var(&to, "to", 0, "Offset to stop printing at, exclusive.")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep printing records as they're appended.")
+	return cmd
+}
+
+// consumeRange prints the records from offset from up to to, exclusive.
+func (c *client) consumeRange(cmd *cobra.Command, from, to uint64) error {
+	for offset := from; offset < to; offset++ {
+		ctx, cancel := c.call(cmd.Context())
+		res, err := c.log.Consume(ctx, &pb.ConsumeRequest{Offset: offset})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("consume offset %d: %w", offset, err)
+		}
+		if err := c.out.record(res.GetRecord()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// follow prints the records from offset from on as they're appended until
+// the command is interrupted.
+func (c *client) follow(cmd *cobra.Command, from uint64) error {
+	stream, err := c.log.ConsumeStream(cmd.Context(), &pb.ConsumeRequest{Offset: from})
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		if errors.Is(err, io.EOF) || cmd.Context().Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.out.record(res.GetRecord()); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *client) offsets(cmd *cobra.Command) (*pb.GetOffsetsResponse, error) {
+	ctx, cancel := c.call(cmd.Context())
+	defer cancel()
+	return c.log.GetOffsets(ctx, &pb.GetOffsetsRequest{})
+}
+
+func newOffsetsCommand(c *client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "offsets",
+		Short: "Print the lowest offset and the high watermark of the log",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			res, err := c.offsets(cmd)
+			if err != nil {
+				return err
+			}
+			return c.out.message(res, func(w io.Writer) error {
+				_, err := fmt.Fprintf(w, "lowest offset:  %d\nhigh watermark: %d\n", res.GetLowestOffset(), res.GetHighWatermark())
+				return err
+			})
+		},
+	}
+}
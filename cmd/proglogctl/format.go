@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+const (
+	// inputLines and inputLengthPrefixed are how produce reads records from
+	// stdin: a record per line, without the newline, or each record's value
+	// after its length as a big-endian uint64, like the log's store files.
+	inputLines          = "lines"
+	inputLengthPrefixed = "length-prefixed"
+
+	// outputJSON writes each message as protojson on a line of its own,
+	// outputRaw a record's bare value and outputHex its value hex encoded,
+	// each followed by a newline. Both write other messages as text.
+	outputJSON = "json"
+	outputRaw  = "raw"
+	outputHex  = "hex"
+
+	// maxValueBytes bounds a value read from stdin, like the HTTP API
+	// bounds a request's body.
+	maxValueBytes = 4 << 20
+)
+
+// readValues calls fn with each value read from r in format, see
+// inputLines.
+func readValues(r io.Reader, format string, fn func([]byte) error) error {
+	switch format {
+	case inputLines:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxValueBytes)
+		for scanner.Scan() {
+			if err := fn(scanner.Bytes()); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	case inputLengthPrefixed:
+		br := bufio.NewReader(r)
+		size := make([]byte, 8)
+		for {
+			if _, err := io.ReadFull(br, size); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return fmt.Errorf("read length: %w", err)
+			}
+			n := binary.BigEndian.Uint64(size)
+			if n > maxValueBytes {
+				return fmt.Errorf("value of %d bytes exceeds %d", n, maxValueBytes)
+			}
+			value := make([]byte, n)
+			if _, err := io.ReadFull(br, value); err != nil {
+				return fmt.Errorf("read value: %w", err)
+			}
+			if err := fn(value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown input format %q: want %s or %s", format, inputLines, inputLengthPrefixed)
+	}
+}
+
+// printer writes what the server answers in an output format, see
+// outputJSON.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (printer, error) {
+	switch format {
+	case outputJSON, outputRaw, outputHex:
+		return printer{w: w, format: format}, nil
+	default:
+		return printer{}, fmt.Errorf("unknown output format %q: want %s, %s or %s", format, outputJSON, outputRaw, outputHex)
+	}
+}
+
+func (p printer) record(record *pb.Record) error {
+	switch p.format {
+	case outputRaw:
+		_, err := fmt.Fprintf(p.w, "%s\n", record.GetValue())
+		return err
+	case outputHex:
+		_, err := fmt.Fprintln(p.w, hex.EncodeToString(record.GetValue()))
+		return err
+	default:
+		return p.json(record)
+	}
+}
+
+// message writes m as JSON, or as text writes it otherwise.
+func (p printer) message(m proto.Message, text func(io.Writer) error) error {
+	if p.format == outputJSON {
+		return p.json(m)
+	}
+	return text(p.w)
+}
+
+func (p printer) json(m proto.Message) error {
+	b, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "%s\n", b)
+	return err
+}
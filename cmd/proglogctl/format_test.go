@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestReadValues(t *testing.T) {
+	read := func(t *testing.T, input []byte, format string) ([]string, error) {
+		t.Helper()
+
+		var values []string
+		err := readValues(bytes.NewReader(input), format, func(value []byte) error {
+			values = append(values, string(value))
+			return nil
+		})
+		return values, err
+	}
+
+	t.Run("OK/Lines", func(t *testing.T) {
+		values, err := read(t, []byte("foo\nbar\n\nbaz"), inputLines)
+		require.NoError(t, err)
+		require.Equal(t, []string{"foo", "bar", "", "baz"}, values, "빈 줄도 레코드이고 마지막 줄은 개행이 없어도 된다")
+	})
+
+	t.Run("OK/LengthPrefixed", func(t *testing.T) {
+		var input []byte
+		for _, value := range []string{"foo\nbar", ""} {
+			input = binary.BigEndian.AppendUint64(input, uint64(len(value)))
+			input = append(input, value...)
+		}
+
+		values, err := read(t, input, inputLengthPrefixed)
+		require.NoError(t, err)
+		require.Equal(t, []string{"foo\nbar", ""}, values, "값에 개행이 있어도 된다")
+	})
+
+	t.Run("Err/Truncated", func(t *testing.T) {
+		input := binary.BigEndian.AppendUint64(nil, 4)
+		input = append(input, "foo"...)
+
+		values, err := read(t, input, inputLengthPrefixed)
+		require.ErrorContains(t, err, "read value")
+		require.Empty(t, values)
+
+		_, err = read(t, input[:4], inputLengthPrefixed)
+		require.ErrorContains(t, err, "read length")
+	})
+
+	t.Run("Err/TooLarge", func(t *testing.T) {
+		_, err := read(t, binary.BigEndian.AppendUint64(nil, maxValueBytes+1), inputLengthPrefixed)
+		require.ErrorContains(t, err, "exceeds")
+	})
+
+	t.Run("Err/UnknownFormat", func(t *testing.T) {
+		_, err := read(t, nil, "csv")
+		require.ErrorContains(t, err, `unknown input format "csv"`)
+	})
+}
+
+func TestPrinter(t *testing.T) {
+	record := &pb.Record{Value: []byte("hello"), Offset: 3}
+
+	for format, want := range map[string]string{
+		outputRaw: "hello\n",
+		outputHex: "68656c6c6f\n",
+	} {
+		var b strings.Builder
+		p, err := newPrinter(&b, format)
+		require.NoError(t, err)
+		require.NoError(t, p.record(record))
+		require.Equal(t, want, b.String())
+	}
+
+	var b strings.Builder
+	p, err := newPrinter(&b, outputJSON)
+	require.NoError(t, err)
+	require.NoError(t, p.record(record))
+	require.NoError(t, p.record(record))
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	require.Len(t, lines, 2, "레코드마다 한 줄에 쓴다")
+	require.JSONEq(t, `{"value": "aGVsbG8=", "offset": "3", "term": "0", "type": 0, "origin": "", "originOffset": "0", "key": "", "headers": {}}`, lines[0])
+
+	_, err = newPrinter(&b, "yaml")
+	require.ErrorContains(t, err, `unknown output format "yaml"`)
+}
@@ -0,0 +1,140 @@
+// Command proglogctl produces to, consumes from and administers a proglog
+// cluster over gRPC.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newCommand().ExecuteContext(ctx); err != nil {
+		stop()
+		os.Exit(1)
+	}
+}
+
+// options are the flags every subcommand shares.
+type options struct {
+	addr          string
+	tlsCertFile   string
+	tlsKeyFile    string
+	tlsCAFile     string
+	tlsServerName string
+	timeout       time.Duration
+	output        string
+}
+
+// client is what the subcommands call the server with.
+type client struct {
+	conn  *grpc.ClientConn
+	log   pb.LogClient
+	admin pb.AdminClient
+	out   printer
+	opts  *options
+}
+
+func newCommand() *cobra.Command {
+	opts := &options{}
+	c := &client{opts: opts}
+
+	cmd := &cobra.Command{
+		Use:          "proglogctl",
+		Short:        "Produce to, consume from and administer a proglog cluster",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return c.dial(cmd)
+		},
+		PersistentPostRunE: func(*cobra.Command, []string) error {
+			return c.conn.Close()
+		},
+	}
+
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.addr, "addr", "127.0.0.1:8400", "RPC address of a server.")
+	flags.StringVar(&opts.tlsCertFile, "tls-cert-file", "", "Path to client tls cert; dials with mTLS if set.")
+	flags.StringVar(&opts.tlsKeyFile, "tls-key-file", "", "Path to client tls key.")
+	flags.StringVar(&opts.tlsCAFile, "tls-ca-file", "", "Path to certificate authority verifying the server.")
+	flags.StringVar(&opts.tlsServerName, "tls-server-name", "", "Name to verify the server's certificate for; defaults to the host of addr.")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "Timeout of each call; streams that follow the log don't time out.")
+	flags.StringVarP(&opts.output, "output", "o", outputJSON, "Output format: json, raw or hex.")
+
+	cmd.AddCommand(
+		newProduceCommand(c),
+		newConsumeCommand(c),
+		newOffsetsCommand(c),
+		newServersCommand(c),
+		newAdminCommand(c),
+	)
+	return cmd
+}
+
+// dial connects to the server and sets up the output of cmd.
+func (c *client) dial(cmd *cobra.Command) error {
+	var err error
+	if c.out, err = newPrinter(cmd.OutOrStdout(), c.opts.output); err != nil {
+		return err
+	}
+
+	creds := insecure.NewCredentials()
+	if c.opts.tlsCertFile != "" || c.opts.tlsCAFile != "" {
+		tlsConfig, err := c.opts.tlsConfig()
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	c.conn, err = grpc.NewClient(c.opts.addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	c.log = pb.NewLogClient(c.conn)
+	c.admin = pb.NewAdminClient(c.conn)
+	return nil
+}
+
+// call returns a context bounding a unary call by the timeout.
+func (c *client) call(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.opts.timeout)
+}
+
+func (o *options) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: o.tlsServerName}
+	if o.tlsCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.tlsCertFile, o.tlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if o.tlsCAFile != "" {
+		ca, err := os.ReadFile(o.tlsCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse CA certificate: %q", o.tlsCAFile)
+		}
+	}
+	return tlsConfig, nil
+}
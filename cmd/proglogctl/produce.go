@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func newProduceCommand(c *client) *cobra.Command {
+	var (
+		input   string
+		key     string
+		headers map[string]string
+	)
+	cmd := &cobra.Command{
+		Use:   "produce",
+		Short: "Produce the records read from stdin and print their offsets",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			template := &pb.Record{Headers: make(map[string][]byte, len(headers))}
+			if key != "" {
+				template.Key = []byte(key)
+			}
+			for k, v := range headers {
+				template.Headers[k] = []byte(v)
+			}
+			return c.produce(cmd, input, template)
+		},
+	}
+	cmd.Flags().StringVar(&input, "input", inputLines, "Input format: lines or length-prefixed, a big-endian uint64 length before each value.")
+	cmd.Flags().StringVar(&key, "key", "", "Key of every record.")
+	cmd.Flags().StringToStringVar(&headers, "header", nil, "Header of every record as name=value; repeatable.")
+	return cmd
+}
+
+// produce streams the values read from stdin as records like template and
+// prints their offsets as the server acknowledges them.
+func (c *client) produce(cmd *cobra.Command, input string, template *pb.Record) error {
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	stream, err := c.log.ProduceStream(ctx)
+	if err != nil {
+		return err
+	}
+
+	// NOTE - 모두 보낸 뒤 받으면 서버의 버퍼가 차서 멈출 수 있으므로 동시에 받는다.
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			res, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				recvErr <- nil
+				return
+			}
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			if err := c.out.message(res, func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, res.GetOffset())
+				return err
+			}); err != nil {
+				recvErr <- err
+				return
+			}
+		}
+	}()
+
+	sendErr := readValues(cmd.InOrStdin(), input, func(value []byte) error {
+		record := &pb.Record{
+			Value:   value,
+			Key:     template.GetKey(),
+			Headers: template.GetHeaders(),
+		}
+		return stream.Send(&pb.ProduceRequest{Record: record})
+	})
+	switch {
+	case sendErr == nil:
+		sendErr = stream.CloseSend()
+	case errors.Is(sendErr, io.EOF):
+		// NOTE - 서버가 스트림을 끝내면 Send는 io.EOF를 돌려주고, 원인은 Recv로 받는다.
+		sendErr = nil
+	default:
+		cancel()
+	}
+	recv := <-recvErr
+	if sendErr != nil {
+		return sendErr
+	}
+	return recv
+}
@@ -1,3 +1,7 @@
 #!/usr/bin/env bash
 
+set -e
+
+# NOTE - buf.lock이 buf.yaml의 deps를 따라가게 한다.
+buf dep update
 buf generate
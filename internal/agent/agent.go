@@ -1,28 +1,70 @@
 package agent
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"contrib.go.opencensus.io/exporter/prometheus"
+	"github.com/hashicorp/raft"
+	"github.com/soheilhy/cmux"
+	"go.opencensus.io/metric/metricproducer"
+	"go.opencensus.io/stats/view"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	"github.com/zrma/proglog/internal/auth"
 	"github.com/zrma/proglog/internal/discovery"
 	"github.com/zrma/proglog/internal/log"
 	"github.com/zrma/proglog/internal/pb"
 	"github.com/zrma/proglog/internal/server"
+	"github.com/zrma/proglog/internal/tracing"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// ErrOldDataDir is returned by New for a Raft node whose DataDir holds
+// segments of the layout from before the log moved into DataDir/log.
+var ErrOldDataDir = errors.New("data dir has segments of the old layout")
+
 type Agent struct {
 	Config
 
-	log        *log.Log
+	mux        cmux.CMux
+	authorizer *auth.Authorizer
+	log        *log.DistributedLog
+	transport  *log.GRPCTransport
+	// NOTE - PullReplication이면 log 대신 localLog에 쓰고 replicator가 피어의 레코드를 가져온다.
+	localLog   *log.Log
+	localConn  *grpc.ClientConn
+	replicator *log.Replicator
+	mirror     *log.Mirror
+	mirrorConn *grpc.ClientConn
+	lag        *log.LagProducer
+	logMetrics *log.LogProducer
+	metrics    *http.Server
+	http       *http.Server
+	probes     *http.Server
+	logger     *zap.Logger
+	logLevel   zap.AtomicLevel
+	tracer     *sdktrace.TracerProvider
+	sampler    *tracing.Sampler
 	server     *grpc.Server
+	// NOTE - svrCfg는 서버가 리더에게 쓰기를 전달하는 연결을 갖고 있어서 서버를 멈춘 뒤 닫는다.
+	svrCfg     *server.Config
+	serving    atomic.Bool
 	membership *discovery.Membership
-	replicator *log.Replicator
 
 	shutdown     bool
 	shutdowns    chan struct{}
@@ -39,6 +81,53 @@ type Config struct {
 	StartJoinPeers  []string
 	ACLModelFile    string
 	ACLPolicyFile   string
+	Bootstrap       bool
+	// NonVoter joins the cluster as a read replica that receives the log but
+	// does not vote.
+	NonVoter bool
+	// RaftOverGRPC carries Raft traffic as a gRPC service on the agent's
+	// grpc.Server instead of a dedicated stream on the RPC port.
+	RaftOverGRPC bool
+	// PullReplication replicates by having each node consume the logs of
+	// its peers with a log.Replicator instead of through Raft. Every node
+	// accepts writes and the logs converge without a common order;
+	// Bootstrap, NonVoter and RaftOverGRPC don't apply.
+	PullReplication bool
+	// MetricsAddr, if set, is where the agent serves its metrics in the
+	// Prometheus text format at /metrics.
+	MetricsAddr string
+	// HTTPAddr, if set, is where the agent serves the log over HTTP, see
+	// server.NewHTTPServer, with ServerTLSConfig like the RPC port.
+	HTTPAddr string
+	// ProbeAddr, if set, is where the agent serves its liveness and
+	// readiness probes, /healthz and /readyz, and /debug/pprof.
+	ProbeAddr string
+	// MirrorSourceAddr, if set, is the RPC address of a server of another
+	// cluster whose log the agent copies into its own cluster, dialed with
+	// MirrorTLSConfig or in plain text, see log.Mirror. Only one node of a
+	// cluster should mirror a source; each one that does copies it again.
+	MirrorSourceAddr string
+	MirrorTLSConfig  *tls.Config
+	// MirrorCheckpointFile is where the mirror keeps the next source offset
+	// to copy; it defaults to mirror-checkpoint in DataDir.
+	MirrorCheckpointFile string
+	// MirrorSkipGaps makes the mirror skip the records the source removed
+	// before they were mirrored, see log.MirrorConfig.SkipGaps.
+	MirrorSkipGaps bool
+	// MaxApplyLag is how many committed entries a follower may have yet to
+	// apply and still be ready; it defaults to 1024.
+	MaxApplyLag uint64
+	// Tracing, if set, installs a global tracer provider exporting the
+	// agent's spans, sampled as Sampling says.
+	Tracing *tracing.Config
+	// Sampling decides which traces are sampled; it defaults to all of
+	// them. The Admin service can change it at runtime.
+	Sampling *tracing.SamplingConfig
+	// LogLevel and LogEncoding, "console" or "json", configure the agent's
+	// logger; they default to debug and console. The Admin service can
+	// change the level at runtime.
+	LogLevel    string
+	LogEncoding string
 }
 
 func (c Config) RPCAddr() (string, error) {
@@ -56,67 +145,326 @@ func New(config Config) (*Agent, error) {
 	}
 	setup := []func() error{
 		agent.setupLogger,
+		agent.setupTracing,
+		agent.setupMux,
+		agent.setupAuthorizer,
 		agent.setupLog,
 		agent.setupServer,
+		agent.setupMetrics,
 		agent.setupMembership,
+		agent.setupMirror,
+		agent.setupProbes,
 	}
 	for _, fn := range setup {
 		if err := fn(); err != nil {
 			return nil, err
 		}
 	}
+	go agent.serve()
 	return agent, nil
 }
 
 func (a *Agent) setupLogger() error {
-	logger, err := zap.NewDevelopment()
+	logConfig := zap.NewDevelopmentConfig()
+	if a.Config.LogLevel != "" {
+		level, err := zap.ParseAtomicLevel(a.Config.LogLevel)
+		if err != nil {
+			return err
+		}
+		logConfig.Level = level
+	}
+	if a.Config.LogEncoding != "" {
+		logConfig.Encoding = a.Config.LogEncoding
+	}
+	a.logLevel = logConfig.Level
+
+	var err error
+	a.logger, err = logConfig.Build()
 	if err != nil {
 		return err
 	}
-	zap.ReplaceGlobals(logger)
+	zap.ReplaceGlobals(a.logger)
 	return nil
 }
 
-func (a *Agent) setupLog() error {
+func (a *Agent) setupTracing() error {
+	if a.Config.Tracing == nil {
+		return nil
+	}
+
+	sampling := tracing.SamplingConfig{Ratio: 1}
+	if a.Config.Sampling != nil {
+		sampling = *a.Config.Sampling
+	}
 	var err error
-	a.log, err = log.NewLog(a.Config.DataDir, log.Config{})
+	a.sampler, err = tracing.NewSampler(sampling)
+	if err != nil {
+		return err
+	}
+
+	tracingConfig := *a.Config.Tracing
+	tracingConfig.Sampler = a.sampler
+	a.tracer, err = tracing.New(context.Background(), tracingConfig)
 	return err
 }
 
-func (a *Agent) setupServer() error {
-	authorizer, err := auth.New(
+func (a *Agent) setupMux() error {
+	rpcAddr, err := a.RPCAddr()
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", rpcAddr)
+	if err != nil {
+		return err
+	}
+	a.mux = cmux.New(ln)
+	return nil
+}
+
+func (a *Agent) setupAuthorizer() error {
+	var err error
+	a.authorizer, err = auth.New(
 		a.Config.ACLModelFile,
 		a.Config.ACLPolicyFile,
 	)
+	return err
+}
+
+func (a *Agent) setupLog() error {
+	if err := a.migrateDataDir(); err != nil {
+		return err
+	}
+	if a.Config.PullReplication {
+		return a.setupReplicator()
+	}
+
+	logConfig := log.Config{}
+	if a.Config.RaftOverGRPC {
+		rpcAddr, err := a.Config.RPCAddr()
+		if err != nil {
+			return err
+		}
+		creds := insecure.NewCredentials()
+		if a.Config.PeerTLSConfig != nil {
+			creds = credentials.NewTLS(a.Config.PeerTLSConfig)
+		}
+		a.transport = log.NewGRPCTransport(rpcAddr, grpc.WithTransportCredentials(creds))
+		logConfig.Raft.Transport = a.transport
+	} else {
+		raftLn := a.mux.Match(func(reader io.Reader) bool {
+			b := make([]byte, 1)
+			if _, err := reader.Read(b); err != nil {
+				return false
+			}
+			return b[0] == log.RaftRPC
+		})
+		logConfig.Raft.StreamLayer = log.NewStreamLayer(
+			raftLn,
+			a.Config.ServerTLSConfig,
+			a.Config.PeerTLSConfig,
+			a.authorizer,
+		)
+	}
+	logConfig.Raft.LocalID = raft.ServerID(a.Config.NodeName)
+	logConfig.Raft.Bootstrap = a.Config.Bootstrap
+	logConfig.Raft.MaxApplyLag = a.Config.MaxApplyLag
+
+	var err error
+	a.log, err = log.NewDistributedLog(a.Config.DataDir, logConfig)
+	if err != nil {
+		return err
+	}
+	a.lag = log.NewLagProducer(a.log.ReplicationLag)
+	metricproducer.GlobalManager().AddProducer(a.lag)
+	a.logMetrics = log.NewLogProducer(a.log)
+	metricproducer.GlobalManager().AddProducer(a.logMetrics)
+
+	if a.Config.Bootstrap {
+		return a.log.WaitForLeader(3 * time.Second)
+	}
+	return nil
+}
+
+// migrateDataDir handles the segments agents left directly in DataDir before
+// the log moved into DataDir/log next to DataDir/raft. Those agents
+// replicated by pulling from their peers, so with PullReplication the
+// segments move into DataDir/log and the node keeps its records. A Raft node
+// refuses to start instead: its log only holds what the cluster's Raft log
+// applied, and starting without the records, or a bootstrapping node leading
+// an empty log, would lose them without a word.
+func (a *Agent) migrateDataDir() error {
+	baseOffsets, err := log.SegmentBaseOffsets(a.Config.DataDir)
+	if errors.Is(err, fs.ErrNotExist) || len(baseOffsets) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(a.Config.DataDir, "log")
+	if !a.Config.PullReplication {
+		return fmt.Errorf(
+			"%w: %s has the segments of a log from before it moved into %s; start with pull replication to move them there, or move them away to start empty",
+			ErrOldDataDir, a.Config.DataDir, dir,
+		)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	baseOffsets, err = log.MoveSegments(a.Config.DataDir, dir)
+	if err != nil {
+		return err
+	}
+	a.logger.Info("moved segments into the log dir",
+		zap.String("dir", dir),
+		zap.Uint64s("base_offsets", baseOffsets),
+	)
+	return nil
+}
+
+// setupReplicator opens a local log, which a Replicator fills with the
+// records of the peers membership finds through the agent's own server.
+func (a *Agent) setupReplicator() error {
+	dir := filepath.Join(a.Config.DataDir, "log")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var err error
+	a.localLog, err = log.NewLog(dir, log.Config{})
 	if err != nil {
 		return err
 	}
+
+	rpcAddr, err := a.Config.RPCAddr()
+	if err != nil {
+		return err
+	}
+	creds := insecure.NewCredentials()
+	if a.Config.PeerTLSConfig != nil {
+		creds = credentials.NewTLS(a.Config.PeerTLSConfig)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	a.localConn, err = grpc.NewClient(rpcAddr, opts...)
+	if err != nil {
+		return err
+	}
+	a.replicator = &log.Replicator{
+		DialOptions: opts,
+		LocalServer: pb.NewLogClient(a.localConn),
+		LocalID:     a.Config.NodeName,
+		ProgressDir: filepath.Join(a.Config.DataDir, "replicator"),
+	}
+	a.lag = log.NewLagProducer(a.replicator.ReplicationLag)
+	metricproducer.GlobalManager().AddProducer(a.lag)
+	return nil
+}
+
+func (a *Agent) setupServer() error {
 	svrCfg := &server.Config{
-		CommitLog:  a.log,
-		Authorizer: authorizer,
+		Authorizer:    a.authorizer,
+		PeerTLSConfig: a.Config.PeerTLSConfig,
+		Logger:        a.logger,
+		LogLevel:      &a.logLevel,
+		Sampler:       a.sampler,
+	}
+	if a.log != nil {
+		svrCfg.CommitLog = a.log
+		svrCfg.LeaderLocator = a.log
+		svrCfg.Membership = a.log
+		svrCfg.ReplicationLag = a.log
+		svrCfg.Readiness = a.log
+	} else {
+		svrCfg.CommitLog = a.localLog
+		svrCfg.ReplicationLag = a.replicator
+	}
+	if a.transport != nil {
+		svrCfg.RaftServer = a.transport.Server()
 	}
 	var opts []grpc.ServerOption
 	if a.Config.ServerTLSConfig != nil {
 		creds := credentials.NewTLS(a.Config.ServerTLSConfig)
 		opts = append(opts, grpc.Creds(creds))
 	}
+	if a.transport != nil {
+		// NOTE - Raft의 AppendEntries를 받을 수 있게 메시지 제한을 올리는 대신 레코드 크기를 따로 제한한다.
+		opts = append(opts, grpc.MaxRecvMsgSize(log.MaxRaftMessageBytes))
+		svrCfg.MaxRecordBytes = log.MaxRecordBytes
+	}
+	var err error
+	a.svrCfg = svrCfg
 	a.server, err = server.NewGRPCServer(svrCfg, opts...)
 	if err != nil {
 		return err
 	}
-	rpcAddr, err := a.RPCAddr()
+	grpcLn := a.mux.Match(cmux.Any())
+	go func() {
+		a.serving.Store(true)
+		defer a.serving.Store(false)
+		if err := a.server.Serve(grpcLn); err != nil {
+			if err0 := a.Shutdown(); err0 != nil {
+				zap.L().Error("failed to shutdown server", zap.Error(err0))
+			}
+		}
+	}()
+	return a.setupHTTP(svrCfg)
+}
+
+func (a *Agent) setupHTTP(svrCfg *server.Config) error {
+	if a.Config.HTTPAddr == "" {
+		return nil
+	}
+	var err error
+	a.http, err = server.NewHTTPServer(a.Config.HTTPAddr, svrCfg)
 	if err != nil {
 		return err
 	}
-	ln, err := net.Listen("tcp", rpcAddr)
+	a.http.ReadHeaderTimeout = 10 * time.Second
+
+	ln, err := net.Listen("tcp", a.Config.HTTPAddr)
 	if err != nil {
 		return err
 	}
+	if a.Config.ServerTLSConfig != nil {
+		ln = tls.NewListener(ln, a.Config.ServerTLSConfig)
+	}
 	go func() {
-		if err := a.server.Serve(ln); err != nil {
-			if err0 := a.Shutdown(); err0 != nil {
-				zap.L().Error("failed to shutdown server", zap.Error(err0))
-			}
+		if err := a.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("failed to serve HTTP", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (a *Agent) setupMetrics() error {
+	if a.Config.MetricsAddr == "" {
+		return nil
+	}
+	if err := view.Register(log.Views...); err != nil {
+		return err
+	}
+
+	// NOTE - 뷰와 GlobalManager에 등록된 producer를 모두 내보낸다.
+	exporter, err := prometheus.NewExporter(prometheus.Options{
+		OnError: func(err error) {
+			zap.L().Error("failed to export metrics", zap.Error(err))
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", a.Config.MetricsAddr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	a.metrics = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := a.metrics.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("failed to serve metrics", zap.Error(err))
 		}
 	}()
 	return nil
@@ -127,31 +475,86 @@ func (a *Agent) setupMembership() error {
 	if err != nil {
 		return err
 	}
-	var opts []grpc.DialOption
+	tags := map[string]string{
+		"rpc_addr": rpcAddr,
+	}
+	if a.Config.NonVoter {
+		tags[discovery.NonvoterTag] = "true"
+	}
+	var handler discovery.Handler = a.replicator
+	if a.log != nil {
+		handler = a.log
+	}
+	a.membership, err = discovery.New(handler, discovery.Config{
+		NodeName:     a.Config.NodeName,
+		BindAddr:     a.Config.BindAddr,
+		Tags:         tags,
+		InitialPeers: a.Config.StartJoinPeers,
+	})
+	return err
+}
+
+// setupMirror copies the log of the source cluster into this one by
+// producing to the agent's own server.
+func (a *Agent) setupMirror() error {
+	if a.Config.MirrorSourceAddr == "" {
+		return nil
+	}
+	rpcAddr, err := a.Config.RPCAddr()
+	if err != nil {
+		return err
+	}
+	creds := insecure.NewCredentials()
 	if a.Config.PeerTLSConfig != nil {
-		creds := credentials.NewTLS(a.Config.PeerTLSConfig)
-		opts = append(opts, grpc.WithTransportCredentials(creds))
+		creds = credentials.NewTLS(a.Config.PeerTLSConfig)
 	}
-	conn, err := grpc.NewClient(rpcAddr, opts...)
+	a.mirrorConn, err = grpc.NewClient(rpcAddr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		return err
 	}
-	client := pb.NewLogClient(conn)
-	a.replicator = &log.Replicator{
-		DialOptions: opts,
-		LocalServer: client,
+
+	sourceCreds := insecure.NewCredentials()
+	if a.Config.MirrorTLSConfig != nil {
+		sourceCreds = credentials.NewTLS(a.Config.MirrorTLSConfig)
 	}
-	a.membership, err = discovery.New(a.replicator, discovery.Config{
-		NodeName: a.Config.NodeName,
-		BindAddr: a.Config.BindAddr,
-		Tags: map[string]string{
-			"rpc_addr": rpcAddr,
-		},
-		InitialPeers: a.Config.StartJoinPeers,
+	checkpointFile := a.Config.MirrorCheckpointFile
+	if checkpointFile == "" {
+		checkpointFile = filepath.Join(a.Config.DataDir, "mirror-checkpoint")
+	}
+	a.mirror, err = log.NewMirror(log.MirrorConfig{
+		SourceAddr:        a.Config.MirrorSourceAddr,
+		SourceDialOptions: []grpc.DialOption{grpc.WithTransportCredentials(sourceCreds)},
+		Target:            pb.NewLogClient(a.mirrorConn),
+		CheckpointFile:    checkpointFile,
+		SkipGaps:          a.Config.MirrorSkipGaps,
 	})
 	return err
 }
 
+func (a *Agent) serve() error {
+	if err := a.mux.Serve(); err != nil {
+		_ = a.Shutdown()
+		return err
+	}
+	return nil
+}
+
+// stepDown hands leadership to the most up-to-date follower so the cluster
+// doesn't sit out an election timeout once this node is gone. A failed
+// transfer, e.g. in a single node cluster, doesn't stop the shutdown.
+func (a *Agent) stepDown() error {
+	if a.log == nil {
+		return nil
+	}
+	if _, isLeader := a.log.Leader(); !isLeader {
+		return nil
+	}
+	if err := a.log.TransferLeadership(""); err != nil {
+		zap.L().Warn("failed to transfer leadership", zap.Error(err))
+	}
+	return nil
+}
+
 func (a *Agent) Shutdown() error {
 	a.shutdownLock.Lock()
 	defer a.shutdownLock.Unlock()
@@ -162,13 +565,67 @@ func (a *Agent) Shutdown() error {
 	close(a.shutdowns)
 
 	shutdown := []func() error{
+		a.stepDown,
 		a.membership.Leave,
-		a.replicator.Close,
+		func() error {
+			if a.mirror == nil {
+				return nil
+			}
+			return errors.Join(a.mirror.Close(), a.mirrorConn.Close())
+		},
+		func() error {
+			if a.replicator == nil {
+				return nil
+			}
+			return a.replicator.Close()
+		},
 		func() error {
 			a.server.GracefulStop()
 			return nil
 		},
-		a.log.Close,
+		func() error {
+			if a.http == nil {
+				return nil
+			}
+			return a.http.Close()
+		},
+		func() error {
+			if a.svrCfg == nil {
+				return nil
+			}
+			return a.svrCfg.Close()
+		},
+		func() error {
+			if a.metrics == nil {
+				return nil
+			}
+			return a.metrics.Close()
+		},
+		func() error {
+			if a.probes == nil {
+				return nil
+			}
+			return a.probes.Close()
+		},
+		func() error {
+			metricproducer.GlobalManager().DeleteProducer(a.lag)
+			if a.logMetrics != nil {
+				metricproducer.GlobalManager().DeleteProducer(a.logMetrics)
+			}
+			return nil
+		},
+		func() error {
+			if a.log == nil {
+				return errors.Join(a.localConn.Close(), a.localLog.Close())
+			}
+			return a.log.Close()
+		},
+		func() error {
+			if a.tracer == nil {
+				return nil
+			}
+			return a.tracer.Shutdown(context.Background())
+		},
 	}
 	for _, fn := range shutdown {
 		if err := fn(); err != nil {
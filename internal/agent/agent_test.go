@@ -3,8 +3,14 @@ package agent_test
 import (
 	"context"
 	"crypto/tls"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,15 +18,25 @@ import (
 	"github.com/travisjeffery/go-dynaport"
 	"github.com/zrma/proglog/internal/agent"
 	"github.com/zrma/proglog/internal/config"
+	"github.com/zrma/proglog/internal/log"
 	"github.com/zrma/proglog/internal/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 func TestAgent(t *testing.T) {
-	t.Skip("Need to implement consensus")
+	t.Run("StreamLayer", func(t *testing.T) {
+		testAgent(t, false)
+	})
+	t.Run("GRPCTransport", func(t *testing.T) {
+		testAgent(t, true)
+	})
+}
 
+func testAgent(t *testing.T, raftOverGRPC bool) {
 	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
 		CertFile:      config.ServerCertFile,
 		KeyFile:       config.ServerKeyFile,
@@ -41,34 +57,20 @@ func TestAgent(t *testing.T) {
 
 	var agents []*agent.Agent
 	for i := range 3 {
-		ports := dynaport.Get(2)
-		membershipPort := ports[0]
-		rpcPort := ports[1]
-
-		bindAddr := fmt.Sprintf("127.0.0.1:%d", membershipPort)
-
-		dataDir, err := os.MkdirTemp("", "agent-test-log")
-		require.NoError(t, err)
-
 		var startJoinAddrs []string
 		if i != 0 {
 			startJoinAddrs = append(startJoinAddrs, agents[0].Config.BindAddr)
 		}
 
-		agent, err := agent.New(agent.Config{
+		agents = append(agents, newAgent(t, agent.Config{
 			NodeName:        fmt.Sprintf("agent-%d", i),
 			StartJoinPeers:  startJoinAddrs,
-			BindAddr:        bindAddr, // membership port
-			RPCPort:         rpcPort,  // gRPC port
-			DataDir:         dataDir,
-			ACLModelFile:    config.ACLModelFile,
-			ACLPolicyFile:   config.ACLPolicyFile,
 			ServerTLSConfig: serverTLSConfig,
 			PeerTLSConfig:   peerTLSConfig,
-		})
-		require.NoError(t, err)
-
-		agents = append(agents, agent)
+			Bootstrap:       i == 0,
+			RaftOverGRPC:    raftOverGRPC,
+			LogLevel:        "info",
+		}))
 	}
 
 	defer func() {
@@ -129,6 +131,433 @@ func TestAgent(t *testing.T) {
 	got := status.Code(err)
 	want := status.Code(pb.ErrOffsetOutOfRange{}.GRPCStatus().Err())
 	require.Equal(t, want, got)
+
+	produceResponse, err = followerClient.Produce(
+		context.Background(),
+		&pb.ProduceRequest{
+			Record: &pb.Record{
+				Value: []byte("bar"),
+			},
+		},
+	)
+	require.NoError(t, err, "follower forwards the write to the leader")
+
+	consumeResponse, err = leaderClient.Consume(
+		context.Background(),
+		&pb.ConsumeRequest{
+			Offset: produceResponse.Offset,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []byte("bar"), consumeResponse.Record.Value)
+
+	// NOTE - HTTP 게이트웨이도 팔로워에 쓴 레코드를 리더로 넘긴다.
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: peerTLSConfig}}
+	res, err := httpClient.Post("https://"+agents[1].Config.HTTPAddr+"/v1/records", "application/json", strings.NewReader(`{"value": "YmF6"}`))
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res, err = httpClient.Get("https://" + agents[0].Config.HTTPAddr + res.Header.Get("Location"))
+	require.NoError(t, err)
+	var record pb.Record
+	b, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	require.NoError(t, err)
+	require.NoError(t, protojson.Unmarshal(b, &record))
+	require.Equal(t, []byte("baz"), record.GetValue())
+
+	adminClient := pb.NewAdminClient(followerConn)
+	serversResponse, err := adminClient.GetServers(context.Background(), &pb.GetServersRequest{})
+	require.NoError(t, err)
+	require.Len(t, serversResponse.Servers, len(agents))
+	for _, server := range serversResponse.Servers {
+		require.Equal(t, server.Id == agents[0].Config.NodeName, server.IsLeader)
+	}
+
+	leaderAdmin := pb.NewAdminClient(leaderConn)
+	require.Eventually(t, func() bool {
+		res, err := leaderAdmin.GetReplicationLag(context.Background(), &pb.GetReplicationLagRequest{})
+		if err != nil || len(res.GetLags()) != len(agents)-1 {
+			return false
+		}
+		for _, lag := range res.GetLags() {
+			if lag.GetLag() != 0 {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond, "리더는 따라잡은 팔로워의 지연을 0으로 보고한다")
+
+	telemetry, err := leaderAdmin.SetTelemetry(context.Background(), &pb.SetTelemetryRequest{
+		Telemetry: &pb.Telemetry{LogLevel: "warn"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "warn", telemetry.GetTelemetry().GetLogLevel())
+
+	for _, agent := range agents {
+		require.Eventually(t, func() bool {
+			code, _ := probe(t, agent, "/readyz")
+			return code == http.StatusOK
+		}, 3*time.Second, 10*time.Millisecond, "리더가 있고 따라잡은 노드는 준비되었다")
+	}
+
+	metrics := scrapeMetrics(t, agents[0])
+	for _, want := range []string{
+		`grpc_io_server_completed_rpcs{grpc_server_method="log.v1.Log/Produce",grpc_server_status="OK"}`,
+		"proglog_append_latency_bucket{",
+		fmt.Sprintf(`proglog_log_highest_offset{log="data",node=%q} 2`, agents[0].Config.NodeName),
+		fmt.Sprintf(`proglog_log_segments{log="raft",node=%q} 1`, agents[0].Config.NodeName),
+		fmt.Sprintf(`proglog_raft_state{node=%q,state="Leader"} 1`, agents[0].Config.NodeName),
+		fmt.Sprintf(`proglog_raft_term{node=%q}`, agents[0].Config.NodeName),
+		fmt.Sprintf(`proglog_replication_lag{kind="raft",peer=%q} 0`, agents[1].Config.NodeName),
+	} {
+		require.Contains(t, metrics, want)
+	}
+
+	require.NoError(t, agents[0].Shutdown())
+
+	// NOTE - 리더가 종료 전에 리더십을 넘기므로 선거 타임아웃(1초)을 기다리지 않음
+	require.Eventually(t, func() bool {
+		res, err := adminClient.GetServers(context.Background(), &pb.GetServersRequest{})
+		if err != nil {
+			return false
+		}
+		for _, server := range res.Servers {
+			if server.IsLeader {
+				return server.Id != agents[0].Config.NodeName
+			}
+		}
+		return false
+	}, 500*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestAgent_PullReplication(t *testing.T) {
+	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.ServerCertFile,
+		KeyFile:       config.ServerKeyFile,
+		CAFile:        config.CAFile,
+		Server:        true,
+		ServerAddress: "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.RootClientCertFile,
+		KeyFile:       config.RootClientKeyFile,
+		CAFile:        config.CAFile,
+		ServerAddress: "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	var agents []*agent.Agent
+	for i := range 3 {
+		var startJoinAddrs []string
+		if i != 0 {
+			startJoinAddrs = append(startJoinAddrs, agents[0].Config.BindAddr)
+		}
+		agents = append(agents, newAgent(t, agent.Config{
+			NodeName:        fmt.Sprintf("pull-%d", i),
+			StartJoinPeers:  startJoinAddrs,
+			ServerTLSConfig: serverTLSConfig,
+			PeerTLSConfig:   peerTLSConfig,
+			PullReplication: true,
+			LogLevel:        "info",
+		}))
+	}
+	defer func() {
+		for _, agent := range agents {
+			require.NoError(t, agent.Shutdown())
+			require.NoError(t, os.RemoveAll(agent.Config.DataDir))
+		}
+	}()
+
+	ctx := context.Background()
+	var conns []*grpc.ClientConn
+	var clients []pb.LogClient
+	for _, a := range agents {
+		conn, client := client(t, a, peerTLSConfig)
+		defer conn.Close()
+		conns = append(conns, conn)
+		clients = append(clients, client)
+	}
+
+	// NOTE - 리더가 없으므로 어느 노드에 써도 된다.
+	for i, value := range []string{"foo", "bar"} {
+		_, err := clients[i].Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte(value)}})
+		require.NoError(t, err)
+	}
+
+	for _, client := range clients {
+		require.Eventually(t, func() bool {
+			var values []string
+			for off := range uint64(3) {
+				res, err := client.Consume(ctx, &pb.ConsumeRequest{Offset: off})
+				if err != nil {
+					break
+				}
+				values = append(values, string(res.GetRecord().GetValue()))
+			}
+			slices.Sort(values)
+			return slices.Equal(values, []string{"bar", "foo"})
+		}, 5*time.Second, 10*time.Millisecond, "모든 노드가 서로의 레코드를 한 번씩만 가져온다")
+	}
+
+	adminClient := pb.NewAdminClient(conns[2])
+	require.Eventually(t, func() bool {
+		res, err := adminClient.GetReplicationLag(ctx, &pb.GetReplicationLagRequest{})
+		if err != nil || len(res.GetLags()) != len(agents)-1 {
+			return false
+		}
+		for _, lag := range res.GetLags() {
+			if lag.GetKind() != log.ReplicatorLagKind || lag.GetLag() != 0 {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond, "Replicator가 피어별 지연을 보고한다")
+
+	_, err = adminClient.GetServers(ctx, &pb.GetServersRequest{})
+	require.Equal(t, codes.Unimplemented, status.Code(err), "Raft 멤버십이 없다")
+
+	code, body := probe(t, agents[2], "/readyz")
+	require.Equal(t, http.StatusOK, code)
+	require.NotContains(t, body, "raft")
+}
+
+func TestAgent_Probes(t *testing.T) {
+	// NOTE - 부트스트랩하지도, 조인하지도 않은 노드는 리더를 알 수 없다.
+	a := newAgent(t, agent.Config{NodeName: "lonely"})
+	defer func() {
+		require.NoError(t, a.Shutdown())
+		require.NoError(t, os.RemoveAll(a.Config.DataDir))
+	}()
+
+	code, body := probe(t, a, "/healthz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok\n", body)
+
+	code, body = probe(t, a, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Contains(t, body, "[+]log ok\n")
+	require.Contains(t, body, "[+]grpc ok\n")
+	require.Contains(t, body, "[+]serf ok\n")
+	require.Contains(t, body, "[-]raft failed: no leader\n")
+
+	code, body = probe(t, a, "/debug/pprof/")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, body, "goroutine")
+}
+
+func TestAgent_Mirror(t *testing.T) {
+	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.ServerCertFile,
+		KeyFile:       config.ServerKeyFile,
+		CAFile:        config.CAFile,
+		Server:        true,
+		ServerAddress: "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.RootClientCertFile,
+		KeyFile:       config.RootClientKeyFile,
+		CAFile:        config.CAFile,
+		ServerAddress: "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	// NOTE - 서로 조인하지 않는 단일 노드 클러스터 두 개를 띄우고 dr이 primary를 미러링한다.
+	primary := newAgent(t, agent.Config{
+		NodeName:        "primary",
+		ServerTLSConfig: serverTLSConfig,
+		PeerTLSConfig:   peerTLSConfig,
+		Bootstrap:       true,
+	})
+	primaryAddr, err := primary.Config.RPCAddr()
+	require.NoError(t, err)
+
+	dr := newAgent(t, agent.Config{
+		NodeName:         "dr",
+		ServerTLSConfig:  serverTLSConfig,
+		PeerTLSConfig:    peerTLSConfig,
+		Bootstrap:        true,
+		MirrorSourceAddr: primaryAddr,
+		MirrorTLSConfig:  peerTLSConfig,
+	})
+	defer func() {
+		// NOTE - 원본 서버가 미러의 스트림을 기다리지 않도록 dr부터 닫는다.
+		for _, agent := range []*agent.Agent{dr, primary} {
+			require.NoError(t, agent.Shutdown())
+			require.NoError(t, os.RemoveAll(agent.Config.DataDir))
+		}
+	}()
+
+	primaryConn, primaryClient := client(t, primary, peerTLSConfig)
+	defer primaryConn.Close()
+
+	ctx := context.Background()
+	produce := func(values ...string) {
+		for _, value := range values {
+			_, err := primaryClient.Produce(ctx, &pb.ProduceRequest{
+				Record: &pb.Record{
+					Key:     []byte("key-" + value),
+					Value:   []byte(value),
+					Headers: map[string][]byte{"h": []byte(value)},
+				},
+			})
+			require.NoError(t, err)
+		}
+	}
+	mirrored := func(t *testing.T, dr *agent.Agent, values ...string) {
+		t.Helper()
+
+		drConn, drClient := client(t, dr, peerTLSConfig)
+		defer drConn.Close()
+
+		require.Eventually(t, func() bool {
+			_, err := drClient.Consume(ctx, &pb.ConsumeRequest{Offset: uint64(len(values) - 1)})
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		for off, value := range values {
+			res, err := drClient.Consume(ctx, &pb.ConsumeRequest{Offset: uint64(off)})
+			require.NoError(t, err)
+			require.Equal(t, []byte(value), res.GetRecord().GetValue())
+			require.Equal(t, []byte("key-"+value), res.GetRecord().GetKey())
+			require.Equal(t, []byte(value), res.GetRecord().GetHeaders()["h"])
+			require.Equal(t, fmt.Appendf(nil, "%d", off), res.GetRecord().GetHeaders()[log.MirrorSourceOffsetHeader])
+		}
+		_, err := drClient.Consume(ctx, &pb.ConsumeRequest{Offset: uint64(len(values))})
+		require.Error(t, err, "레코드를 한 번씩만 미러링한다")
+	}
+
+	produce("foo", "bar")
+	mirrored(t, dr, "foo", "bar")
+
+	// NOTE - 재시작한 dr이 이어서 미러링하도록 다음 원본 오프셋을 데이터 디렉터리에 남긴다.
+	require.NoError(t, dr.Shutdown())
+	checkpoint, err := os.ReadFile(filepath.Join(dr.Config.DataDir, "mirror-checkpoint"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), binary.BigEndian.Uint64(checkpoint))
+}
+
+func TestAgent_OldDataDir(t *testing.T) {
+	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.ServerCertFile,
+		KeyFile:       config.ServerKeyFile,
+		CAFile:        config.CAFile,
+		Server:        true,
+		ServerAddress: "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.RootClientCertFile,
+		KeyFile:       config.RootClientKeyFile,
+		CAFile:        config.CAFile,
+		ServerAddress: "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	// NOTE - 로그가 DataDir/log로 옮겨 가기 전의 에이전트처럼 DataDir에 바로 세그먼트를 남긴다.
+	dataDir := t.TempDir()
+	l, err := log.NewLog(dataDir, log.Config{})
+	require.NoError(t, err)
+	_, err = l.Append(&pb.Record{Value: []byte("foo")})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	_, err = agent.New(testConfig(t, agent.Config{
+		NodeName:        "old",
+		DataDir:         dataDir,
+		ServerTLSConfig: serverTLSConfig,
+		PeerTLSConfig:   peerTLSConfig,
+		Bootstrap:       true,
+	}))
+	require.ErrorIs(t, err, agent.ErrOldDataDir, "Raft 노드는 옛 세그먼트를 두고 빈 로그로 시작하지 않는다")
+
+	a := newAgent(t, agent.Config{
+		NodeName:        "old",
+		DataDir:         dataDir,
+		ServerTLSConfig: serverTLSConfig,
+		PeerTLSConfig:   peerTLSConfig,
+		PullReplication: true,
+	})
+	defer func() {
+		require.NoError(t, a.Shutdown())
+	}()
+
+	conn, client := client(t, a, peerTLSConfig)
+	defer conn.Close()
+
+	res, err := client.Consume(context.Background(), &pb.ConsumeRequest{Offset: 0})
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), res.GetRecord().GetValue(), "PullReplication은 옛 세그먼트를 DataDir/log로 옮겨 이어 쓴다")
+
+	baseOffsets, err := log.SegmentBaseOffsets(dataDir)
+	require.NoError(t, err)
+	require.Empty(t, baseOffsets)
+}
+
+// newAgent starts an agent with testConfig(t, cfg).
+func newAgent(t *testing.T, cfg agent.Config) *agent.Agent {
+	t.Helper()
+
+	a, err := agent.New(testConfig(t, cfg))
+	require.NoError(t, err)
+
+	return a
+}
+
+// testConfig fills in fresh ports, the ACL files and, unless cfg has one, a
+// fresh data dir.
+func testConfig(t *testing.T, cfg agent.Config) agent.Config {
+	t.Helper()
+
+	ports := dynaport.Get(5)
+	cfg.BindAddr = fmt.Sprintf("127.0.0.1:%d", ports[0])    // membership port
+	cfg.RPCPort = ports[1]                                  // gRPC port
+	cfg.MetricsAddr = fmt.Sprintf("127.0.0.1:%d", ports[2]) // metrics port
+	cfg.HTTPAddr = fmt.Sprintf("127.0.0.1:%d", ports[3])    // HTTP port
+	cfg.ProbeAddr = fmt.Sprintf("127.0.0.1:%d", ports[4])   // probe port
+	cfg.ACLModelFile = config.ACLModelFile
+	cfg.ACLPolicyFile = config.ACLPolicyFile
+
+	if cfg.DataDir == "" {
+		dataDir, err := os.MkdirTemp("", "agent-test-log")
+		require.NoError(t, err)
+		cfg.DataDir = dataDir
+	}
+
+	return cfg
+}
+
+func scrapeMetrics(t *testing.T, agent *agent.Agent) string {
+	t.Helper()
+
+	res, err := http.Get("http://" + agent.Config.MetricsAddr + "/metrics")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+// probe GETs path from the agent's probe listener and returns the status
+// code and body.
+func probe(t *testing.T, agent *agent.Agent, path string) (int, string) {
+	t.Helper()
+
+	res, err := http.Get("http://" + agent.Config.ProbeAddr + path)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(b)
 }
 
 func client(t *testing.T, agent *agent.Agent, tlsConfig *tls.Config) (*grpc.ClientConn, pb.LogClient) {
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// setupProbes serves the agent's admin endpoints on ProbeAddr: /healthz, ok
+// as long as the process runs, /readyz, ok once the agent can serve, see
+// ready, and the runtime profiles at /debug/pprof.
+func (a *Agent) setupProbes() error {
+	if a.Config.ProbeAddr == "" {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", a.Config.ProbeAddr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /readyz", a.handleReadyz)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	a.probes = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := a.probes.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("failed to serve probes", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// handleReadyz answers 200 if the agent is ready and 503 otherwise, with a
+// line per check like Kubernetes' own /readyz.
+func (a *Agent) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	var b strings.Builder
+	ready := true
+	for _, check := range a.readinessChecks() {
+		if err := check.fn(); err != nil {
+			ready = false
+			fmt.Fprintf(&b, "[-]%s failed: %v\n", check.name, err)
+			continue
+		}
+		fmt.Fprintf(&b, "[+]%s ok\n", check.name)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = fmt.Fprint(w, b.String())
+}
+
+type readinessCheck struct {
+	name string
+	fn   func() error
+}
+
+// readinessChecks are what /readyz checks: the agent isn't shutting down and,
+// in the order the agent sets them up, the log is open, the gRPC server
+// serves, serf joined the cluster, and raft has a leader this node is caught
+// up with, see log.DistributedLog.Ready. Without Raft, see
+// Config.PullReplication, there's no raft check.
+func (a *Agent) readinessChecks() []readinessCheck {
+	checks := []readinessCheck{
+		{"shutdown", func() error {
+			select {
+			case <-a.shutdowns:
+				return errors.New("shutting down")
+			default:
+				return nil
+			}
+		}},
+		{"log", func() error {
+			if a.log == nil && a.localLog == nil {
+				return errors.New("not open")
+			}
+			return nil
+		}},
+		{"grpc", func() error {
+			if !a.serving.Load() {
+				return errors.New("not serving")
+			}
+			return nil
+		}},
+		{"serf", func() error {
+			if a.membership == nil {
+				return errors.New("not started")
+			}
+			return a.membership.Ready()
+		}},
+	}
+	if a.Config.PullReplication {
+		return checks
+	}
+	return append(checks, readinessCheck{"raft", func() error {
+		if a.log == nil {
+			return errors.New("not open")
+		}
+		return a.log.Ready()
+	}})
+}
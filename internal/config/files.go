@@ -18,9 +18,10 @@ var (
 )
 
 func init() {
+	// NOTE - 테스트용 인증서 경로다. .cert 폴더 없이 실행되는 바이너리도 SetupTLSConfig를 쓰므로 패닉하지 않고 비워 둔다.
 	projectRoot, err := findProjectRoot(".cert")
 	if err != nil {
-		panic("Could not find .cert folder in project root: " + err.Error())
+		return
 	}
 
 	CAFile = certPath(projectRoot, "ca.pem")
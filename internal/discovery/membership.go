@@ -1,8 +1,11 @@
 package discovery
 
 import (
+	"errors"
+	"fmt"
 	"net"
 
+	"github.com/hashicorp/raft"
 	"github.com/hashicorp/serf/serf"
 	"go.uber.org/zap"
 )
@@ -12,6 +15,14 @@ type Handler interface {
 	Leave(name string) error
 }
 
+// NonvoterHandler is implemented by handlers that can add members which
+// replicate without voting. Members tagged with NonvoterTag join through it.
+type NonvoterHandler interface {
+	JoinNonvoter(name, addr string) error
+}
+
+const NonvoterTag = "nonvoter"
+
 type Membership struct {
 	Config
 	handler Handler
@@ -99,7 +110,11 @@ func (m *Membership) isLocal(member serf.Member) bool {
 }
 
 func (m *Membership) handleJoin(member serf.Member) {
-	if err := m.handler.Join(member.Name, member.Tags["rpc_addr"]); err != nil {
+	join := m.handler.Join
+	if h, ok := m.handler.(NonvoterHandler); ok && member.Tags[NonvoterTag] == "true" {
+		join = h.JoinNonvoter
+	}
+	if err := join(member.Name, member.Tags["rpc_addr"]); err != nil {
 		m.logError(err, "failed to join", member)
 	}
 }
@@ -114,12 +129,35 @@ func (m *Membership) Members() []serf.Member {
 	return m.serf.Members()
 }
 
+// Ready returns why this node isn't part of the cluster, or nil if it is:
+// serf is alive and, when there were peers to join, it knows another live
+// member.
+func (m *Membership) Ready() error {
+	if state := m.serf.State(); state != serf.SerfAlive {
+		return fmt.Errorf("serf is %s", state)
+	}
+	if len(m.InitialPeers) == 0 {
+		return nil
+	}
+	for _, member := range m.serf.Members() {
+		if !m.isLocal(member) && member.Status == serf.StatusAlive {
+			return nil
+		}
+	}
+	return errors.New("no peer joined")
+}
+
 func (m *Membership) Leave() error {
 	return m.serf.Leave()
 }
 
 func (m *Membership) logError(err error, msg string, member serf.Member) {
-	m.logger.Error(
+	log := m.logger.Error
+	if errors.Is(err, raft.ErrNotLeader) {
+		// only the leader can change membership, so followers skip it
+		log = m.logger.Debug
+	}
+	log(
 		msg,
 		zap.Error(err),
 		zap.String("name", member.Name),
@@ -28,8 +28,12 @@ func TestMembership(t *testing.T) {
 			len(first.Members()) == len(members) &&
 			len(handler.leaves) == 0
 	}, 3*time.Second, 250*time.Millisecond)
+	for _, m := range members {
+		require.NoError(t, m.Ready())
+	}
 
 	require.NoError(t, last.Leave())
+	require.Error(t, last.Ready(), "클러스터를 떠난 멤버는 준비되지 않았다")
 
 	require.Eventually(t, func() bool {
 		return len(handler.joins) == wantJoinCnt &&
@@ -0,0 +1,290 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+// NOTE - 아카이브는 레코드를 스토어와 같은 길이 접두 형식으로 담아서 세그먼트 크기와 상관없이 옮길 수 있다.
+// 헤더는 압축하지 않고, 그 뒤는 헤더의 compression대로 압축한다. checksum은 헤더와 압축 전 레코드의 CRC-32C다.
+//
+//	magic | compression | firstOffset | count | count * (length, record) | checksum
+var archiveMagic = []byte("PLA1")
+
+const archiveHeaderWidth = 1 + 8 + 8
+
+var (
+	ErrInvalidArchive = errors.New("invalid archive")
+	ErrLogNotEmpty    = errors.New("log is not empty")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Compression is how an archive is compressed after its header.
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	}
+	return fmt.Sprintf("Compression(%d)", c)
+}
+
+// ParseCompression returns the Compression named s, e.g. "gzip".
+func ParseCompression(s string) (Compression, error) {
+	for _, c := range []Compression{CompressionNone, CompressionGzip} {
+		if c.String() == s {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown compression %q", s)
+}
+
+// ArchiveHeader describes the records of an archive.
+type ArchiveHeader struct {
+	Compression Compression
+	// FirstOffset is the offset of the first record; the others follow it
+	// without gaps.
+	FirstOffset uint64
+	Count       uint64
+}
+
+// LastOffset is the offset of the archive's last record.
+func (h ArchiveHeader) LastOffset() uint64 {
+	return h.FirstOffset + h.Count - 1
+}
+
+func (h ArchiveHeader) appendTo(b []byte) []byte {
+	b = append(b, archiveMagic...)
+	b = append(b, byte(h.Compression))
+	b = enc.AppendUint64(b, h.FirstOffset)
+	return enc.AppendUint64(b, h.Count)
+}
+
+// ReadArchiveHeader reads the header of the archive from r and returns it
+// with its encoding, which the archive's checksum covers.
+func ReadArchiveHeader(r io.Reader) (ArchiveHeader, []byte, error) {
+	b := make([]byte, len(archiveMagic)+archiveHeaderWidth)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ArchiveHeader{}, nil, fmt.Errorf("%w: header is truncated", ErrInvalidArchive)
+		}
+		return ArchiveHeader{}, nil, err
+	}
+	if !bytes.Equal(b[:len(archiveMagic)], archiveMagic) {
+		return ArchiveHeader{}, nil, fmt.Errorf("%w: unknown magic %q", ErrInvalidArchive, b[:len(archiveMagic)])
+	}
+
+	head := b[len(archiveMagic):]
+	h := ArchiveHeader{
+		Compression: Compression(head[0]),
+		FirstOffset: enc.Uint64(head[1:9]),
+		Count:       enc.Uint64(head[9:17]),
+	}
+	if h.Compression > CompressionGzip {
+		return h, nil, fmt.Errorf("%w: unknown compression %d", ErrInvalidArchive, head[0])
+	}
+	if h.Count == 0 || h.LastOffset() < h.FirstOffset {
+		return h, nil, fmt.Errorf("%w: %d records from offset %d", ErrInvalidArchive, h.Count, h.FirstOffset)
+	}
+	return h, b, nil
+}
+
+// Export writes the records from offset from through to to w as an archive
+// compressed with compression.
+func (l *Log) Export(w io.Writer, from, to uint64, compression Compression) error {
+	if from > to {
+		return fmt.Errorf("invalid offset range [%d, %d]", from, to)
+	}
+	if compression > CompressionGzip {
+		return fmt.Errorf("unknown compression %d", compression)
+	}
+	lowest, next := l.offsetRange()
+	if from < lowest {
+		return pb.ErrOffsetOutOfRange{Offset: from}
+	}
+	if to >= next {
+		return pb.ErrOffsetOutOfRange{Offset: to}
+	}
+
+	head := ArchiveHeader{Compression: compression, FirstOffset: from, Count: to - from + 1}.appendTo(nil)
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+
+	var body io.WriteCloser = nopWriteCloser{w}
+	if compression == CompressionGzip {
+		body = gzip.NewWriter(w)
+	}
+	bw := bufio.NewWriter(body)
+	sum := crc32.New(castagnoli)
+	_, _ = sum.Write(head)
+	out := io.MultiWriter(bw, sum)
+
+	var length []byte
+	for off := from; off <= to; off++ {
+		record, err := l.Read(off)
+		if err != nil {
+			return err
+		}
+		p, err := proto.Marshal(record)
+		if err != nil {
+			return err
+		}
+		length = enc.AppendUint64(length[:0], uint64(len(p)))
+		if _, err := out.Write(length); err != nil {
+			return err
+		}
+		if _, err := out.Write(p); err != nil {
+			return err
+		}
+	}
+
+	if _, err := bw.Write(enc.AppendUint32(nil, sum.Sum32())); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// Import appends the records of the archive read from r to the log, which
+// must be empty, keeping their offsets. If the archive turns out to be
+// invalid, the records imported so far are removed again.
+func (l *Log) Import(r io.Reader) (ArchiveHeader, error) {
+	h, head, err := ReadArchiveHeader(r)
+	if err != nil {
+		return h, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.segments) > 1 || l.activeSegment.nextOffset != l.activeSegment.baseOffset {
+		return h, ErrLogNotEmpty
+	}
+	if err := l.resetSegments(h.FirstOffset); err != nil {
+		return h, err
+	}
+
+	if err := l.importRecords(h, head, r); err != nil {
+		// NOTE - 잘못된 아카이브를 가져오다 만 로그를 남기지 않는다.
+		return h, errors.Join(err, l.resetSegments(h.FirstOffset))
+	}
+	return h, nil
+}
+
+func (l *Log) importRecords(h ArchiveHeader, head []byte, r io.Reader) error {
+	body := r
+	if h.Compression == CompressionGzip {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+	sum := crc32.New(castagnoli)
+	_, _ = sum.Write(head)
+	in := io.TeeReader(bufio.NewReader(body), sum)
+
+	var p bytes.Buffer
+	length := make([]byte, lenWidth)
+	for i := range h.Count {
+		off := h.FirstOffset + i
+		if err := readArchive(in, length, "length of record %d", off); err != nil {
+			return err
+		}
+		// NOTE - 망가진 길이 때문에 한꺼번에 큰 버퍼를 잡지 않도록 읽은 만큼만 늘린다.
+		p.Reset()
+		size := enc.Uint64(length)
+		if n, err := io.CopyN(&p, in, int64(size)); err != nil || uint64(n) != size {
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return fmt.Errorf("%w: record %d is truncated", ErrInvalidArchive, off)
+		}
+
+		record := &pb.Record{}
+		if err := proto.Unmarshal(p.Bytes(), record); err != nil {
+			return fmt.Errorf("%w: record %d doesn't decode: %w", ErrInvalidArchive, off, err)
+		}
+		if record.GetOffset() != off {
+			return fmt.Errorf("%w: record %d says its offset is %d", ErrInvalidArchive, off, record.GetOffset())
+		}
+
+		if l.activeSegment.IsMaxed() {
+			if err := l.newSegment(l.activeSegment.nextOffset); err != nil {
+				return err
+			}
+		}
+		if _, err := l.activeSegment.Append(record); err != nil {
+			return err
+		}
+	}
+
+	want := sum.Sum32()
+	checksum := make([]byte, 4)
+	if err := readArchive(in, checksum, "checksum"); err != nil {
+		return err
+	}
+	if got := enc.Uint32(checksum); got != want {
+		return fmt.Errorf("%w: checksum is %08x but the records sum to %08x", ErrInvalidArchive, got, want)
+	}
+	return nil
+}
+
+// readArchive fills b from r, reporting a short read as a truncated archive.
+func readArchive(r io.Reader, b []byte, format string, args ...any) error {
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: %s is truncated", ErrInvalidArchive, fmt.Sprintf(format, args...))
+		}
+		return err
+	}
+	return nil
+}
+
+// resetSegments removes every segment of the log and starts an empty one at
+// off.
+func (l *Log) resetSegments(off uint64) error {
+	for _, s := range l.segments {
+		if err := s.Remove(); err != nil {
+			return err
+		}
+	}
+	return l.replaceSegments(nil, off)
+}
+
+// offsetRange returns the lowest offset of the log and the offset its next
+// record will get.
+func (l *Log) offsetRange() (lowest, next uint64) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.segments[0].baseOffset, l.activeSegment.nextOffset
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
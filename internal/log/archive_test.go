@@ -0,0 +1,115 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestArchive(t *testing.T) {
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 3
+	src, err := NewLog(t.TempDir(), c)
+	require.NoError(t, err)
+	defer func() { _ = src.Close() }()
+	for i := range 10 {
+		_, err := src.Append(&pb.Record{
+			Value:   fmt.Appendf(nil, "record %d", i),
+			Term:    uint64(i),
+			Key:     []byte("key"),
+			Headers: map[string][]byte{"i": {byte(i)}},
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, src.Truncate(2))
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip} {
+		t.Run("OK/"+compression.String(), func(t *testing.T) {
+			var archive bytes.Buffer
+			require.NoError(t, src.Export(&archive, 3, 8, compression))
+
+			c := Config{}
+			c.Segment.MaxIndexBytes = entWidth * 4
+			dst, err := NewLog(t.TempDir(), c)
+			require.NoError(t, err)
+			defer func() { _ = dst.Close() }()
+
+			h, err := dst.Import(&archive)
+			require.NoError(t, err)
+			require.Equal(t, ArchiveHeader{Compression: compression, FirstOffset: 3, Count: 6}, h)
+			require.Zero(t, archive.Len(), "아카이브를 끝까지 읽는다")
+
+			lowest, err := dst.LowestOffset()
+			require.NoError(t, err)
+			require.Equal(t, uint64(3), lowest, "오프셋을 그대로 유지한다")
+			highest, err := dst.HighestOffset()
+			require.NoError(t, err)
+			require.Equal(t, uint64(8), highest)
+			require.Len(t, dst.segments, 2, "가져오는 로그의 세그먼트 크기를 따른다")
+
+			for off := uint64(3); off <= 8; off++ {
+				want, err := src.Read(off)
+				require.NoError(t, err)
+				got, err := dst.Read(off)
+				require.NoError(t, err)
+				require.Equal(t, want.GetValue(), got.GetValue())
+				require.Equal(t, want.GetTerm(), got.GetTerm())
+				require.Equal(t, want.GetKey(), got.GetKey())
+				require.Equal(t, want.GetHeaders(), got.GetHeaders())
+			}
+
+			off, err := dst.Append(&pb.Record{Value: []byte("next")})
+			require.NoError(t, err)
+			require.Equal(t, uint64(9), off, "가져온 뒤에는 이어서 쓴다")
+		})
+	}
+
+	t.Run("Err/Range", func(t *testing.T) {
+		var archive bytes.Buffer
+		require.ErrorIs(t, src.Export(&archive, 1, 5, CompressionNone), pb.ErrOffsetOutOfRange{Offset: 1})
+		require.ErrorIs(t, src.Export(&archive, 5, 10, CompressionNone), pb.ErrOffsetOutOfRange{Offset: 10})
+		require.ErrorContains(t, src.Export(&archive, 5, 4, CompressionNone), "invalid offset range")
+		require.Zero(t, archive.Len(), "범위를 확인하기 전에 쓰지 않는다")
+	})
+
+	t.Run("Err/NotEmpty", func(t *testing.T) {
+		var archive bytes.Buffer
+		require.NoError(t, src.Export(&archive, 3, 3, CompressionNone))
+
+		_, err := src.Import(&archive)
+		require.ErrorIs(t, err, ErrLogNotEmpty)
+	})
+
+	t.Run("Err/Invalid", func(t *testing.T) {
+		var archive bytes.Buffer
+		require.NoError(t, src.Export(&archive, 3, 9, CompressionNone))
+		valid := archive.Bytes()
+
+		for name, corrupt := range map[string]func([]byte) []byte{
+			"magic":     func(b []byte) []byte { b[0] = 'X'; return b },
+			"header":    func(b []byte) []byte { return b[:10] },
+			"truncated": func(b []byte) []byte { return b[:len(b)-20] },
+			"checksum":  func(b []byte) []byte { b[len(b)-1]++; return b },
+			"record":    func(b []byte) []byte { b[len(b)-10]++; return b },
+		} {
+			t.Run(name, func(t *testing.T) {
+				dst, err := NewLog(t.TempDir(), c)
+				require.NoError(t, err)
+				defer func() { _ = dst.Close() }()
+
+				_, err = dst.Import(bytes.NewReader(corrupt(bytes.Clone(valid))))
+				require.ErrorIs(t, err, ErrInvalidArchive)
+				require.False(t, errors.Is(err, ErrLogNotEmpty))
+
+				_, err = dst.Read(3)
+				require.Error(t, err, "가져오다 만 레코드는 지운다")
+				require.Len(t, dst.segments, 1)
+			})
+		}
+	})
+}
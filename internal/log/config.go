@@ -6,7 +6,14 @@ type Config struct {
 	Raft struct {
 		raft.Config
 		StreamLayer raft.StreamLayer
-		Bootstrap   bool
+		// Transport, if set, is used instead of a NetworkTransport over
+		// StreamLayer, e.g. a GRPCTransport.
+		Transport raft.Transport
+		Bootstrap bool
+		// MaxApplyLag is how many entries the leader has committed that a
+		// follower may have yet to apply and still be ready, see
+		// DistributedLog.Ready. It defaults to 1024.
+		MaxApplyLag uint64
 	}
 	Segment struct {
 		MaxStoreBytes uint64
@@ -2,7 +2,10 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"os"
@@ -12,14 +15,17 @@ import (
 	"github.com/hashicorp/raft"
 	raftboltdb "github.com/hashicorp/raft-boltdb"
 	"github.com/zrma/proglog/internal/pb"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
 )
 
 type DistributedLog struct {
-	Config  Config
-	log     *Log
-	raftLog *logStore
-	raft    *raft.Raft
+	Config      Config
+	log         *Log
+	raftLog     *logStore
+	stableStore *raftboltdb.BoltStore
+	transport   *lagTransport
+	raft        *raft.Raft
 }
 
 func NewDistributedLog(dataDir string, config Config) (*DistributedLog, error) {
@@ -66,7 +72,7 @@ func (l *DistributedLog) setupRaft(dataDir string) error {
 		return err
 	}
 
-	stableStore, err := raftboltdb.NewBoltStore(filepath.Join(dataDir, "raft", "stable"))
+	l.stableStore, err = raftboltdb.NewBoltStore(filepath.Join(dataDir, "raft", "stable"))
 	if err != nil {
 		return err
 	}
@@ -82,10 +88,15 @@ func (l *DistributedLog) setupRaft(dataDir string) error {
 		timeout = 10 * time.Second
 	)
 
-	transport := raft.NewNetworkTransport(l.Config.Raft.StreamLayer, maxPool, timeout, os.Stderr)
+	transport := l.Config.Raft.Transport
+	if transport == nil {
+		transport = raft.NewNetworkTransport(l.Config.Raft.StreamLayer, maxPool, timeout, os.Stderr)
+	}
+	l.transport = newLagTransport(transport)
 
 	raftConfig := raft.DefaultConfig()
 	raftConfig.LocalID = l.Config.Raft.LocalID
+	raftConfig.MaxAppendEntries = maxAppendEntries
 
 	if l.Config.Raft.HeartbeatTimeout > 0 {
 		raftConfig.HeartbeatTimeout = l.Config.Raft.HeartbeatTimeout
@@ -100,12 +111,12 @@ func (l *DistributedLog) setupRaft(dataDir string) error {
 		raftConfig.CommitTimeout = l.Config.Raft.CommitTimeout
 	}
 
-	l.raft, err = raft.NewRaft(raftConfig, fsm, l.raftLog, stableStore, snapshotStore, transport)
+	l.raft, err = raft.NewRaft(raftConfig, fsm, l.raftLog, l.stableStore, snapshotStore, l.transport)
 	if err != nil {
 		return err
 	}
 
-	hasState, err := raft.HasExistingState(l.raftLog, stableStore, snapshotStore)
+	hasState, err := raft.HasExistingState(l.raftLog, l.stableStore, snapshotStore)
 	if err != nil {
 		return err
 	}
@@ -125,7 +136,12 @@ func (l *DistributedLog) setupRaft(dataDir string) error {
 	return err
 }
 
-func (l *DistributedLog) Append(record *pb.Record) (uint64, error) {
+func (l *DistributedLog) Append(record *pb.Record) (_ uint64, err error) {
+	_, span := startSpan(context.Background(), record, "raft.Apply")
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	res, err := l.apply(AppendRequestType, &pb.ProduceRequest{Record: record})
 	if err != nil {
 		return 0, err
@@ -164,10 +180,189 @@ func (l *DistributedLog) apply(reqType RequestType, req proto.Message) (any, err
 	return res, nil
 }
 
+func (l *DistributedLog) LowestOffset() (uint64, error) {
+	return l.log.LowestOffset()
+}
+
+func (l *DistributedLog) HighestOffset() (uint64, error) {
+	return l.log.HighestOffset()
+}
+
 func (l *DistributedLog) Read(offset uint64) (*pb.Record, error) {
 	return l.log.Read(offset)
 }
 
+// Leader returns the current leader's Raft address and whether this node is
+// the leader. The address is empty while no leader is known.
+func (l *DistributedLog) Leader() (string, bool) {
+	addr, _ := l.raft.LeaderWithID()
+	return string(addr), l.raft.State() == raft.Leader
+}
+
+const defaultMaxApplyLag = 1024
+
+// Ready returns why the node can't serve yet, or nil if it can: a leader is
+// known and, on a follower, the log has caught up with the leader's commit
+// index to within MaxApplyLag entries.
+func (l *DistributedLog) Ready() error {
+	switch l.raft.State() {
+	case raft.Leader:
+		return nil
+	case raft.Shutdown:
+		return errors.New("raft is shut down")
+	}
+	if addr, _ := l.raft.LeaderWithID(); addr == "" {
+		return errors.New("no leader")
+	}
+
+	maxLag := l.Config.Raft.MaxApplyLag
+	if maxLag == 0 {
+		maxLag = defaultMaxApplyLag
+	}
+	commit, applied := l.transport.leaderCommit.Load(), l.raft.AppliedIndex()
+	if commit > applied+maxLag {
+		return fmt.Errorf("catching up: applied %d of %d committed entries", applied, commit)
+	}
+	return nil
+}
+
+// Join adds the server to the cluster as a voter. Only the leader can change
+// membership; followers return raft.ErrNotLeader.
+func (l *DistributedLog) Join(id, addr string) error {
+	return l.join(id, addr, raft.Voter)
+}
+
+// JoinNonvoter adds the server to the cluster as a non-voting member that
+// receives the log but takes no part in elections, e.g. a read replica.
+func (l *DistributedLog) JoinNonvoter(id, addr string) error {
+	return l.join(id, addr, raft.Nonvoter)
+}
+
+func (l *DistributedLog) join(id, addr string, suffrage raft.ServerSuffrage) error {
+	configFuture := l.raft.GetConfiguration()
+	if err := configFuture.Error(); err != nil {
+		return err
+	}
+
+	serverID := raft.ServerID(id)
+	serverAddr := raft.ServerAddress(addr)
+
+	for _, srv := range configFuture.Configuration().Servers {
+		if srv.ID != serverID && srv.Address != serverAddr {
+			continue
+		}
+		if srv.ID == serverID && srv.Address == serverAddr && srv.Suffrage == suffrage {
+			// server has already joined
+			return nil
+		}
+		if srv.ID == serverID && srv.Address == serverAddr && suffrage == raft.Voter {
+			// promoting a non-voter in place
+			break
+		}
+		// remove the existing server
+		if err := l.raft.RemoveServer(srv.ID, 0, 0).Error(); err != nil {
+			return err
+		}
+	}
+
+	var addFuture raft.IndexFuture
+	if suffrage == raft.Voter {
+		addFuture = l.raft.AddVoter(serverID, serverAddr, 0, 0)
+	} else {
+		addFuture = l.raft.AddNonvoter(serverID, serverAddr, 0, 0)
+	}
+	return addFuture.Error()
+}
+
+// Leave removes the server from the cluster.
+func (l *DistributedLog) Leave(id string) error {
+	removeFuture := l.raft.RemoveServer(raft.ServerID(id), 0, 0)
+	return removeFuture.Error()
+}
+
+// TransferLeadership hands leadership over to the voter with the given id,
+// or to the most up-to-date voter when id is empty. Only the leader can
+// transfer leadership.
+func (l *DistributedLog) TransferLeadership(id string) error {
+	var future raft.Future
+	if id == "" {
+		future = l.raft.LeadershipTransfer()
+	} else {
+		configFuture := l.raft.GetConfiguration()
+		if err := configFuture.Error(); err != nil {
+			return err
+		}
+
+		var target *raft.Server
+		for _, srv := range configFuture.Configuration().Servers {
+			if srv.ID == raft.ServerID(id) {
+				target = &srv
+				break
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("unknown server: %s", id)
+		}
+		future = l.raft.LeadershipTransferToServer(target.ID, target.Address)
+	}
+
+	return future.Error()
+}
+
+func (l *DistributedLog) GetServers() ([]*pb.Server, error) {
+	future := l.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, err
+	}
+
+	leaderAddr, _ := l.raft.LeaderWithID()
+
+	var servers []*pb.Server
+	for _, srv := range future.Configuration().Servers {
+		servers = append(servers, &pb.Server{
+			Id:       string(srv.ID),
+			RpcAddr:  string(srv.Address),
+			IsLeader: leaderAddr == srv.Address,
+			Nonvoter: srv.Suffrage == raft.Nonvoter,
+		})
+	}
+	return servers, nil
+}
+
+func (l *DistributedLog) WaitForLeader(timeout time.Duration) error {
+	timeoutc := time.After(timeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timeoutc:
+			return errors.New("timed out waiting for leader")
+		case <-ticker.C:
+			if addr, _ := l.raft.LeaderWithID(); addr != "" {
+				return nil
+			}
+		}
+	}
+}
+
+func (l *DistributedLog) Close() error {
+	f := l.raft.Shutdown()
+	if err := f.Error(); err != nil {
+		return err
+	}
+
+	if err := l.raftLog.Close(); err != nil {
+		return err
+	}
+
+	if err := l.stableStore.Close(); err != nil {
+		return err
+	}
+
+	return l.log.Close()
+}
+
 var _ raft.FSM = (*fsm)(nil)
 
 type fsm struct {
@@ -198,7 +393,9 @@ func (f *fsm) applyAppend(b []byte) any {
 		return err
 	}
 
-	offset, err := f.log.Append(req.Record)
+	ctx, span := startSpan(context.Background(), req.Record, "fsm.Apply")
+	offset, err := f.log.append(ctx, req.Record)
+	endSpan(span, err)
 	if err != nil {
 		return err
 	}
@@ -207,63 +404,21 @@ func (f *fsm) applyAppend(b []byte) any {
 }
 
 func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
-	r := f.log.Reader()
-	return &snapshot{reader: r}, nil
-}
-
-func (f *fsm) Restore(r io.ReadCloser) error {
-	b := make([]byte, lenWidth)
-	var buf bytes.Buffer
-	for i := 0; ; i++ {
-		_, err := io.ReadFull(r, b)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}
-
-		size := int64(enc.Uint64(b))
-		if _, err := io.CopyN(&buf, r, size); err != nil {
-			return err
-		}
-
-		var record pb.Record
-		if err := proto.Unmarshal(buf.Bytes(), &record); err != nil {
-			return err
-		}
-
-		if i == 0 {
-			f.log.Config.Segment.InitialOffset = record.Offset
-			if err := f.log.Reset(); err != nil {
-				return err
-			}
-		}
-
-		if _, err := f.log.Append(&record); err != nil {
-			return err
-		}
-
-		buf.Reset()
+	views, err := f.log.snapshot()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return &snapshot{views: views}, nil
 }
 
-var _ raft.FSMSnapshot = (*snapshot)(nil)
-
-type snapshot struct {
-	reader io.Reader
-}
-
-func (s *snapshot) Persist(sink raft.SnapshotSink) error {
-	if _, err := io.Copy(sink, s.reader); err != nil {
-		_ = sink.Cancel()
+func (f *fsm) Restore(r io.ReadCloser) error {
+	m, err := readManifest(r)
+	if err != nil {
 		return err
 	}
-	return sink.Close()
+	return f.log.restore(m, r)
 }
 
-func (s *snapshot) Release() {}
-
 var _ raft.LogStore = (*logStore)(nil)
 
 type logStore struct {
@@ -275,20 +430,52 @@ func newLogStore(dir string, config Config) (*logStore, error) {
 	if err != nil {
 		return nil, err
 	}
+	l.name = raftLogName
 	return &logStore{l}, nil
 }
 
+var (
+	ErrLogGap                 = errors.New("raft log entries must be contiguous")
+	ErrUnsupportedDeleteRange = errors.New("cannot delete a range from the middle of the raft log")
+)
+
 func (l *logStore) FirstIndex() (uint64, error) {
-	return l.LowestOffset()
+	first, _, ok := l.bounds()
+	if !ok {
+		return 0, nil
+	}
+	return first, nil
 }
 
 func (l *logStore) LastIndex() (uint64, error) {
-	return l.HighestOffset()
+	_, last, ok := l.bounds()
+	if !ok {
+		return 0, nil
+	}
+	return last, nil
+}
+
+// bounds returns the first and last index in the store; ok is false while
+// the store holds no entries.
+func (l *logStore) bounds() (first, last uint64, ok bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	first = l.segments[0].baseOffset
+	next := l.segments[len(l.segments)-1].nextOffset
+	if next == first {
+		return 0, 0, false
+	}
+	return first, next - 1, true
 }
 
 func (l *logStore) GetLog(index uint64, out *raft.Log) error {
 	in, err := l.Read(index)
 	if err != nil {
+		var errOutOfRange pb.ErrOffsetOutOfRange
+		if errors.As(err, &errOutOfRange) {
+			return raft.ErrLogNotFound
+		}
 		return err
 	}
 
@@ -305,36 +492,108 @@ func (l *logStore) StoreLog(record *raft.Log) error {
 }
 
 func (l *logStore) StoreLogs(records []*raft.Log) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if _, last, ok := l.bounds(); ok {
+		if records[0].Index != last+1 {
+			return ErrLogGap
+		}
+	} else if err := l.rebase(records[0].Index); err != nil {
+		return err
+	}
+
 	for _, record := range records {
-		if _, err := l.Append(&pb.Record{
+		off, err := l.Append(&pb.Record{
 			Value: record.Data,
 			Term:  record.Term,
 			Type:  uint32(record.Type),
-		}); err != nil {
+		})
+		if err != nil {
 			return err
 		}
+		if off != record.Index {
+			return ErrLogGap
+		}
 	}
 	return nil
 }
 
-func (l *logStore) DeleteRange(_, max uint64) error {
-	return l.Truncate(max)
+// rebase makes an empty store hand out index as the next offset.
+func (l *logStore) rebase(index uint64) error {
+	next, err := l.LowestOffset()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case index > next:
+		return l.truncatePrefix(index-1, true)
+	case index < next:
+		return l.TruncateSuffix(index)
+	}
+	return nil
 }
 
+// DeleteRange deletes the entries between from and to inclusive.
+//
+// Only a prefix or a suffix can be deleted: the store is a Log, whose
+// offsets are contiguous, so it can't hold a hole, and splicing the entries
+// after to down to from would renumber them, which Raft forbids. Raft never
+// asks for more: it deletes a prefix when it compacts after a snapshot, a
+// suffix when a follower's entries conflict with the leader's, and every
+// entry when a cluster is recovered. Anything else fails with
+// ErrUnsupportedDeleteRange and leaves the store as it was.
+func (l *logStore) DeleteRange(from, to uint64) error {
+	first, last, ok := l.bounds()
+	if !ok || from > to || to < first || from > last {
+		return nil
+	}
+
+	switch {
+	case to >= last:
+		return l.TruncateSuffix(max(from, first))
+	case from <= first:
+		return l.truncatePrefix(to, true)
+	}
+	return fmt.Errorf("%w: [%d, %d] of [%d, %d]", ErrUnsupportedDeleteRange, from, to, first, last)
+}
+
+// Authorizer decides whether the peer identified by a certificate's common
+// name may act on the cluster, e.g. *auth.Authorizer.
+type Authorizer interface {
+	Authorize(subject, object, action string) error
+}
+
+const (
+	objectWildcard = "*"
+	raftAction     = "raft"
+)
+
+var ErrUnauthorizedPeer = errors.New("peer is not allowed to speak raft")
+
 var _ raft.StreamLayer = (*StreamLayer)(nil)
 
 type StreamLayer struct {
 	ln              net.Listener
 	serverTLSConfig *tls.Config
 	peerTLSConfig   *tls.Config
+	authorizer      Authorizer
+	logger          *zap.Logger
 }
 
-func NewStreamLayer(ln net.Listener, serverTLSConfig, peerTLSConfig *tls.Config,
+// NewStreamLayer creates a stream layer serving Raft on ln. If authorizer is
+// set, only TLS peers whose certificate is allowed the raft action may speak
+// Raft; any other client signed by the CA is rejected.
+func NewStreamLayer(ln net.Listener, serverTLSConfig, peerTLSConfig *tls.Config, authorizer Authorizer,
 ) *StreamLayer {
 	return &StreamLayer{
 		ln:              ln,
 		serverTLSConfig: serverTLSConfig,
 		peerTLSConfig:   peerTLSConfig,
+		authorizer:      authorizer,
+		logger:          zap.L().Named("raft"),
 	}
 }
 
@@ -351,11 +610,26 @@ func (s *StreamLayer) Dial(addr raft.ServerAddress, timeout time.Duration) (net.
 		return nil, err
 	}
 	if s.peerTLSConfig != nil {
-		conn = tls.Client(conn, s.peerTLSConfig)
+		conn = tls.Client(conn, clientTLSConfig(s.peerTLSConfig, string(addr)))
 	}
 	return conn, nil
 }
 
+// clientTLSConfig verifies the server at addr by its host, like gRPC does,
+// unless config names the server itself.
+func clientTLSConfig(config *tls.Config, addr string) *tls.Config {
+	if config.ServerName != "" || config.InsecureSkipVerify {
+		return config
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return config
+	}
+	config = config.Clone()
+	config.ServerName = host
+	return config
+}
+
 func (s *StreamLayer) Accept() (net.Conn, error) {
 	for {
 		conn, err := s.ln.Accept()
@@ -372,12 +646,52 @@ func (s *StreamLayer) Accept() (net.Conn, error) {
 			continue
 		}
 		if s.serverTLSConfig != nil {
-			conn = tls.Server(conn, s.serverTLSConfig)
+			conn = tls.Server(conn, s.tlsConfigFor(conn.RemoteAddr()))
 		}
 		return conn, nil
 	}
 }
 
+// tlsConfigFor verifies the peer as part of the TLS handshake, which runs on
+// the connection's first read in Raft's own goroutine, so a slow or rejected
+// peer never stalls Accept and never gets a byte through to Raft.
+func (s *StreamLayer) tlsConfigFor(remote net.Addr) *tls.Config {
+	if s.authorizer == nil {
+		return s.serverTLSConfig
+	}
+
+	cfg := s.serverTLSConfig.Clone()
+	verify := cfg.VerifyConnection
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		if err := s.authorizePeer(cs); err != nil {
+			s.logger.Warn(
+				"rejected raft peer",
+				zap.Stringer("remote_addr", remote),
+				zap.Error(err),
+			)
+			return err
+		}
+		return nil
+	}
+	return cfg
+}
+
+func (s *StreamLayer) authorizePeer(cs tls.ConnectionState) error {
+	if len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return ErrUnauthorizedPeer
+	}
+	subject := cs.VerifiedChains[0][0].Subject.CommonName
+	if err := s.authorizer.Authorize(subject, objectWildcard, raftAction); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnauthorizedPeer, err)
+	}
+	return nil
+}
+
 func (s *StreamLayer) Close() error {
 	return s.ln.Close()
 }
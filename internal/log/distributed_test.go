@@ -0,0 +1,718 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+	"github.com/travisjeffery/go-dynaport"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/zrma/proglog/internal/auth"
+	"github.com/zrma/proglog/internal/config"
+	"github.com/zrma/proglog/internal/pb"
+	"github.com/zrma/proglog/internal/tracing"
+)
+
+func TestDistributedLog_Membership(t *testing.T) {
+	var logs []*DistributedLog
+	const nodeCount = 4
+	ports := dynaport.Get(nodeCount)
+
+	for i := 0; i < nodeCount; i++ {
+		l := newDistributedLog(t, i, ports[i])
+		addr := l.Config.Raft.StreamLayer.Addr().String()
+
+		if i == 0 {
+			require.NoError(t, l.WaitForLeader(3*time.Second))
+		} else if i < nodeCount-1 {
+			require.NoError(t, logs[0].Join(fmt.Sprintf("%d", i), addr))
+		} else {
+			require.NoError(t, logs[0].JoinNonvoter(fmt.Sprintf("%d", i), addr))
+		}
+
+		logs = append(logs, l)
+	}
+
+	addr, isLeader := logs[1].Leader()
+	require.False(t, isLeader)
+	require.Equal(t, logs[0].Config.Raft.StreamLayer.Addr().String(), addr)
+
+	err := logs[1].Join("9", "127.0.0.1:1")
+	require.ErrorIs(t, err, raft.ErrNotLeader, "only the leader can change membership")
+
+	records := []*pb.Record{
+		{Value: []byte("first")},
+		{Value: []byte("second")},
+	}
+	for _, record := range records {
+		off, err := logs[0].Append(record)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			for j := 0; j < nodeCount; j++ {
+				got, err := logs[j].Read(off)
+				if err != nil || !bytes.Equal(record.Value, got.Value) {
+					return false
+				}
+			}
+			return true
+		}, 3*time.Second, 50*time.Millisecond)
+	}
+
+	servers, err := logs[0].GetServers()
+	require.NoError(t, err)
+	require.Len(t, servers, nodeCount)
+	require.True(t, servers[0].IsLeader)
+	require.False(t, servers[1].IsLeader)
+	require.False(t, servers[1].Nonvoter)
+	require.True(t, servers[nodeCount-1].Nonvoter)
+
+	require.NoError(t, logs[0].Join("3", servers[nodeCount-1].RpcAddr), "promote the non-voter")
+	servers, err = logs[0].GetServers()
+	require.NoError(t, err)
+	require.Len(t, servers, nodeCount)
+	require.False(t, servers[nodeCount-1].Nonvoter)
+
+	require.NoError(t, logs[0].Leave("1"))
+
+	servers, err = logs[0].GetServers()
+	require.NoError(t, err)
+	require.Len(t, servers, nodeCount-1)
+
+	off, err := logs[0].Append(&pb.Record{Value: []byte("third")})
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+
+	record, err := logs[1].Read(off)
+	require.IsType(t, pb.ErrOffsetOutOfRange{}, err, "a removed server stops receiving records")
+	require.Nil(t, record)
+
+	require.Eventually(t, func() bool {
+		record, err := logs[2].Read(off)
+		return err == nil && bytes.Equal([]byte("third"), record.Value)
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestDistributedLog_TransferLeadership(t *testing.T) {
+	var logs []*DistributedLog
+	const nodeCount = 3
+	ports := dynaport.Get(nodeCount)
+
+	for i := 0; i < nodeCount; i++ {
+		l := newDistributedLog(t, i, ports[i])
+		if i == 0 {
+			require.NoError(t, l.WaitForLeader(3*time.Second))
+		} else {
+			addr := l.Config.Raft.StreamLayer.Addr().String()
+			require.NoError(t, logs[0].Join(fmt.Sprintf("%d", i), addr))
+		}
+		logs = append(logs, l)
+	}
+
+	off, err := logs[0].Append(&pb.Record{Value: []byte("before transfer")})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		for _, l := range logs {
+			if _, err := l.Read(off); err != nil {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond)
+
+	require.ErrorIs(t, logs[1].TransferLeadership(""), raft.ErrNotLeader)
+	require.Error(t, logs[0].TransferLeadership("unknown"))
+
+	require.NoError(t, logs[0].TransferLeadership("2"))
+	require.Eventually(t, func() bool {
+		_, isLeader := logs[2].Leader()
+		return isLeader
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, logs[2].TransferLeadership(""))
+	require.Eventually(t, func() bool {
+		_, isLeader := logs[2].Leader()
+		addr, _ := logs[0].Leader()
+		return !isLeader && addr != "" && addr != logs[2].Config.Raft.StreamLayer.Addr().String()
+	}, time.Second, 10*time.Millisecond)
+
+	off, err = logs[0].Append(&pb.Record{Value: []byte("after transfer")})
+	if errors.Is(err, raft.ErrNotLeader) {
+		off, err = logs[1].Append(&pb.Record{Value: []byte("after transfer")})
+	}
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		record, err := logs[2].Read(off)
+		return err == nil && bytes.Equal([]byte("after transfer"), record.Value)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDistributedLog_ReplicationLag(t *testing.T) {
+	var logs []*DistributedLog
+	const nodeCount = 3
+	ports := dynaport.Get(nodeCount)
+
+	for i := 0; i < nodeCount; i++ {
+		l := newDistributedLog(t, i, ports[i])
+		if i == 0 {
+			require.NoError(t, l.WaitForLeader(3*time.Second))
+		} else {
+			addr := l.Config.Raft.StreamLayer.Addr().String()
+			require.NoError(t, logs[0].Join(fmt.Sprintf("%d", i), addr))
+		}
+		logs = append(logs, l)
+	}
+
+	for range 10 {
+		_, err := logs[0].Append(&pb.Record{Value: []byte("lag")})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		lags := logs[0].ReplicationLag()
+		if len(lags) != nodeCount-1 {
+			return false
+		}
+		for _, lag := range lags {
+			if lag.GetKind() != RaftLagKind || lag.GetLag() != 0 {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond, "팔로워가 따라잡으면 지연이 0이 된다")
+	require.Empty(t, logs[1].ReplicationLag(), "팔로워는 다른 노드의 지연을 모른다")
+
+	metrics := NewLagProducer(logs[0].ReplicationLag).Read()
+	require.Len(t, metrics, 1)
+	require.Equal(t, replicationLagMetric, metrics[0].Descriptor.Name)
+	require.Len(t, metrics[0].TimeSeries, nodeCount-1)
+
+	require.NoError(t, logs[0].JoinNonvoter("unreachable", "127.0.0.1:1"))
+	lags := logs[0].ReplicationLag()
+	require.Len(t, lags, nodeCount)
+	for _, lag := range lags {
+		if lag.GetPeer() == "unreachable" {
+			require.NotZero(t, lag.GetLag(), "응답하지 않는 팔로워는 리더의 로그 전체만큼 뒤처진 것으로 본다")
+		}
+	}
+}
+
+func TestDistributedLog_Ready(t *testing.T) {
+	ports := dynaport.Get(2)
+	leader := newDistributedLog(t, 0, ports[0])
+	follower := newDistributedLog(t, 1, ports[1])
+
+	require.NoError(t, leader.WaitForLeader(3*time.Second))
+	require.NoError(t, leader.Ready())
+	require.ErrorContains(t, follower.Ready(), "no leader", "클러스터에 들어가기 전에는 준비되지 않았다")
+
+	require.NoError(t, leader.Join("1", follower.Config.Raft.StreamLayer.Addr().String()))
+	require.Eventually(t, func() bool {
+		return follower.Ready() == nil
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// NOTE - 리더가 한참 앞서 커밋했다고 알려 온 상황을 흉내 낸다.
+	follower.transport.leaderCommit.Store(follower.raft.AppliedIndex() + defaultMaxApplyLag + 1)
+	require.ErrorContains(t, follower.Ready(), "catching up")
+
+	_, err := leader.Append(&pb.Record{Value: []byte("caught up")})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return follower.Ready() == nil
+	}, 3*time.Second, 10*time.Millisecond, "리더의 커밋 인덱스를 따라잡으면 다시 준비된다")
+}
+
+func TestDistributedLog_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := tracing.New(context.Background(), tracing.Config{
+		Exporters: []sdktrace.SpanExporter{exporter},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+	})
+
+	l := newDistributedLog(t, 0, dynaport.Get(1)[0])
+	require.NoError(t, l.WaitForLeader(3*time.Second))
+
+	_, err = l.Append(&pb.Record{Value: []byte("untraced")})
+	require.NoError(t, err)
+	require.Empty(t, exporter.GetSpans(), "트레이스 없이 쓴 레코드는 트레이스를 시작하지 않는다")
+
+	ctx, producer := tp.Tracer("test").Start(context.Background(), "produce")
+	// NOTE - 첫 레코드로 세그먼트가 가득 차므로 두 번째 레코드는 새 세그먼트에 쓴다.
+	var offsets []uint64
+	for range 2 {
+		record := &pb.Record{Value: bytes.Repeat([]byte("a"), 1024)}
+		tracing.Inject(ctx, record)
+		off, err := l.Append(record)
+		require.NoError(t, err)
+		offsets = append(offsets, off)
+	}
+	producer.End()
+
+	spans := map[string][]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		require.Equal(t, producer.SpanContext().TraceID(), span.SpanContext.TraceID(), "모든 span이 프로듀서의 트레이스에 속한다")
+		spans[span.Name] = append(spans[span.Name], span)
+	}
+	require.Len(t, spans["raft.Apply"], 2)
+	require.Len(t, spans["fsm.Apply"], 2)
+	require.Len(t, spans["log.Append"], 2)
+	require.Len(t, spans["log.rollover"], 1)
+	require.Equal(t, spans["log.Append"][1].SpanContext.SpanID(), spans["log.rollover"][0].Parent.SpanID())
+
+	for i := range 2 {
+		require.Equal(t, producer.SpanContext().SpanID(), spans["raft.Apply"][i].Parent.SpanID())
+		require.Equal(t, producer.SpanContext().SpanID(), spans["fsm.Apply"][i].Parent.SpanID())
+		require.Equal(t, spans["fsm.Apply"][i].SpanContext.SpanID(), spans["log.Append"][i].Parent.SpanID())
+	}
+
+	record, err := l.Read(offsets[1])
+	require.NoError(t, err)
+	require.Equal(t, producer.SpanContext().SpanID(), tracing.Link(record).SpanContext.SpanID(), "레코드에 프로듀서의 트레이스가 남는다")
+}
+
+func newDistributedLog(t *testing.T, id int, port int) *DistributedLog {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	require.NoError(t, err)
+
+	cfg := Config{}
+	cfg.Raft.StreamLayer = NewStreamLayer(ln, nil, nil, nil)
+	cfg.Raft.LocalID = raft.ServerID(fmt.Sprintf("%d", id))
+	cfg.Raft.HeartbeatTimeout = 50 * time.Millisecond
+	cfg.Raft.ElectionTimeout = 50 * time.Millisecond
+	cfg.Raft.LeaderLeaseTimeout = 50 * time.Millisecond
+	cfg.Raft.CommitTimeout = 5 * time.Millisecond
+	cfg.Raft.Bootstrap = id == 0
+
+	l, err := NewDistributedLog(t.TempDir(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, l.Close())
+	})
+
+	return l
+}
+
+func TestStreamLayer_PeerAuthorization(t *testing.T) {
+	authorizer, err := auth.New(config.ACLModelFile, config.ACLPolicyFile)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.ServerCertFile,
+		KeyFile:       config.ServerKeyFile,
+		CAFile:        config.CAFile,
+		ServerAddress: "127.0.0.1",
+		Server:        true,
+	})
+	require.NoError(t, err)
+
+	layer := NewStreamLayer(ln, serverTLSConfig, nil, authorizer)
+	t.Cleanup(func() {
+		_ = layer.Close()
+	})
+
+	dial := func(t *testing.T, certFile, keyFile string) {
+		t.Helper()
+
+		peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+			CertFile:      certFile,
+			KeyFile:       keyFile,
+			CAFile:        config.CAFile,
+			ServerAddress: "127.0.0.1",
+		})
+		require.NoError(t, err)
+
+		peer := NewStreamLayer(nil, nil, peerTLSConfig, nil)
+		conn, err := peer.Dial(raft.ServerAddress(ln.Addr().String()), time.Second)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+
+		// NOTE - 핸드셰이크는 Accept 쪽에서 읽을 때 진행되므로 쓰기는 따로 돌린다.
+		go func() {
+			_, _ = conn.Write([]byte("raft"))
+		}()
+	}
+
+	t.Run("OK/RootClient", func(t *testing.T) {
+		dial(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+		conn, err := layer.Accept()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		b := make([]byte, 4)
+		_, err = io.ReadFull(conn, b)
+		require.NoError(t, err)
+		require.Equal(t, []byte("raft"), b)
+	})
+
+	t.Run("Err/NobodyClient", func(t *testing.T) {
+		dial(t, config.NobodyClientCertFile, config.NobodyClientKeyFile)
+
+		conn, err := layer.Accept()
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = conn.Read(make([]byte, 4))
+		require.ErrorIs(t, err, ErrUnauthorizedPeer, "CA가 서명했더라도 raft 권한이 없으면 거부한다")
+	})
+}
+
+func TestFSM_SnapshotRestore(t *testing.T) {
+	src := newFixture(t)
+
+	want := &pb.Record{
+		Value: []byte("hello world"),
+	}
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		_, err := src.log.Append(want)
+		require.NoError(t, err)
+	}
+	require.Greater(t, len(src.log.segments), 1, "여러 세그먼트에 걸쳐 기록되어야 함")
+
+	snap, err := (&fsm{log: src.log}).Snapshot()
+	require.NoError(t, err)
+
+	sink := &fakeSink{}
+	require.NoError(t, snap.Persist(sink))
+	require.True(t, sink.closed)
+
+	size := len(snapshotMagic) + offWidth
+	for _, s := range src.log.segments {
+		size += manifestEntryWidth + int(s.store.size+s.index.size)
+	}
+	require.Equal(t, size, sink.Len(), "매니페스트와 세그먼트 파일만 담아야 함")
+
+	dst := newFixture(t)
+	_, err = dst.log.Append(&pb.Record{Value: []byte("stale")})
+	require.NoError(t, err)
+
+	err = (&fsm{log: dst.log}).Restore(io.NopCloser(bytes.NewReader(sink.Bytes())))
+	require.NoError(t, err)
+
+	lowest, err := dst.log.LowestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), lowest)
+
+	highest, err := dst.log.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(count-1), highest)
+
+	for off := uint64(0); off < count; off++ {
+		got, err := dst.log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, want.GetValue(), got.GetValue())
+		require.Equal(t, off, got.GetOffset())
+	}
+
+	off, err := dst.log.Append(want)
+	require.NoError(t, err)
+	require.Equal(t, uint64(count), off)
+}
+
+func TestFSM_SnapshotConcurrentAppend(t *testing.T) {
+	src := newFixture(t)
+
+	want := &pb.Record{
+		Value: []byte("hello world"),
+	}
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		_, err := src.log.Append(want)
+		require.NoError(t, err)
+	}
+
+	snap, err := (&fsm{log: src.log}).Snapshot()
+	require.NoError(t, err)
+	defer snap.Release()
+
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		close(started)
+		for i := 0; i < count*4; i++ {
+			if _, err := src.log.Append(&pb.Record{Value: []byte("appended after snapshot")}); err != nil {
+				done <- err
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		done <- nil
+	}()
+	<-started
+
+	sink := &fakeSink{delay: time.Millisecond}
+	require.NoError(t, snap.Persist(sink))
+	require.NoError(t, <-done)
+
+	dst := newFixture(t)
+	err = (&fsm{log: dst.log}).Restore(io.NopCloser(bytes.NewReader(sink.Bytes())))
+	require.NoError(t, err)
+
+	highest, err := dst.log.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(count-1), highest, "스냅샷 이후에 추가된 레코드는 포함되지 않아야 함")
+
+	for off := uint64(0); off < count; off++ {
+		got, err := dst.log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, want.GetValue(), got.GetValue())
+	}
+}
+
+func TestFSM_SnapshotOutlivesSegments(t *testing.T) {
+	src := newFixture(t)
+
+	want := &pb.Record{
+		Value: []byte("hello world"),
+	}
+
+	const count = 3
+	for i := 0; i < count; i++ {
+		_, err := src.log.Append(want)
+		require.NoError(t, err)
+	}
+
+	snap, err := (&fsm{log: src.log}).Snapshot()
+	require.NoError(t, err)
+	defer snap.Release()
+
+	require.NoError(t, src.log.Truncate(count-2))
+	require.NoError(t, src.log.Remove())
+
+	sink := &fakeSink{}
+	require.NoError(t, snap.Persist(sink))
+
+	dst := newFixture(t)
+	err = (&fsm{log: dst.log}).Restore(io.NopCloser(bytes.NewReader(sink.Bytes())))
+	require.NoError(t, err)
+
+	for off := uint64(0); off < count; off++ {
+		got, err := dst.log.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, want.GetValue(), got.GetValue())
+	}
+}
+
+func TestFSM_RestoreInvalid(t *testing.T) {
+	src := newFixture(t)
+	for i := 0; i < 3; i++ {
+		_, err := src.log.Append(&pb.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	snap, err := (&fsm{log: src.log}).Snapshot()
+	require.NoError(t, err)
+	sink := &fakeSink{}
+	require.NoError(t, snap.Persist(sink))
+	valid := sink.Bytes()
+	head := len(snapshotMagic) + offWidth
+
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"garbage": func([]byte) []byte { return []byte("garbage!") },
+		"count": func(b []byte) []byte {
+			return enc.AppendUint32(b[:len(snapshotMagic)], maxSnapshotSegments+1)
+		},
+		"truncated": func(b []byte) []byte { return b[:len(b)-5] },
+		"record": func(b []byte) []byte {
+			// NOTE - 마지막 세그먼트의 첫 레코드 길이를 바꾼다.
+			last := src.log.activeSegment
+			b[len(b)-int(last.index.size+last.store.size)+lenWidth-1]++
+			return b
+		},
+		"gap": func(b []byte) []byte {
+			enc.PutUint64(b[head+manifestEntryWidth:], 10)
+			return b
+		},
+		"index": func(b []byte) []byte {
+			enc.PutUint64(b[head+16:], src.log.Config.Segment.MaxIndexBytes+entWidth)
+			return b
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dst := newFixture(t)
+			_, err := dst.log.Append(&pb.Record{Value: []byte("stale")})
+			require.NoError(t, err)
+
+			err = (&fsm{log: dst.log}).Restore(io.NopCloser(bytes.NewReader(corrupt(bytes.Clone(valid)))))
+			require.ErrorIs(t, err, ErrInvalidSnapshot)
+
+			got, err := dst.log.Read(0)
+			require.NoError(t, err, "잘못된 스냅샷은 기존 세그먼트를 건드리지 않는다")
+			require.Equal(t, []byte("stale"), got.GetValue())
+			_, err = os.Stat(filepath.Join(dst.log.Dir, restoringDir))
+			require.ErrorIs(t, err, os.ErrNotExist)
+		})
+	}
+}
+
+func TestLogStore_MatchesInmemStore(t *testing.T) {
+	for seed := uint64(0); seed < 20; seed++ {
+		t.Run(fmt.Sprintf("seed=%d", seed), func(t *testing.T) {
+			rnd := rand.New(rand.NewPCG(seed, seed))
+
+			dir := t.TempDir()
+			cfg := Config{}
+			cfg.Segment.MaxStoreBytes = 64
+			cfg.Segment.InitialOffset = 1
+
+			got, err := newLogStore(dir, cfg)
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, got.Close())
+			}()
+
+			want := raft.NewInmemStore()
+
+			for step := 0; step < 200; step++ {
+				first, err := want.FirstIndex()
+				require.NoError(t, err)
+				last, err := want.LastIndex()
+				require.NoError(t, err)
+
+				switch op := rnd.IntN(10); {
+				case op < 5 || last == 0:
+					// NOTE - 비어있을 때는 스냅샷 이후처럼 임의의 인덱스부터 시작할 수 있음
+					next := last + 1
+					if last == 0 {
+						next = first + 1 + rnd.Uint64N(5)
+					}
+					var logs []*raft.Log
+					for i := range 1 + rnd.IntN(5) {
+						logs = append(logs, &raft.Log{
+							Index: next + uint64(i),
+							Term:  1 + rnd.Uint64N(3),
+							Type:  raft.LogCommand,
+							Data:  []byte(fmt.Sprintf("log-%d-%d", step, i)),
+						})
+					}
+					require.NoError(t, want.StoreLogs(logs))
+					require.NoError(t, got.StoreLogs(logs))
+				case op < 7:
+					// NOTE - 리더와 충돌한 팔로워의 로그 뒷부분 삭제
+					from := first + rnd.Uint64N(last-first+1)
+					require.NoError(t, want.DeleteRange(from, last))
+					require.NoError(t, got.DeleteRange(from, last))
+				case op < 9:
+					// NOTE - 스냅샷 이후 로그 앞부분 압축
+					to := first + rnd.Uint64N(last-first+1)
+					require.NoError(t, want.DeleteRange(first, to))
+					require.NoError(t, got.DeleteRange(first, to))
+				default:
+					require.NoError(t, got.Close())
+					got, err = newLogStore(dir, cfg)
+					require.NoError(t, err)
+				}
+
+				requireSameLogStore(t, want, got)
+			}
+		})
+	}
+}
+
+func TestLogStore_DeleteRangeMiddle(t *testing.T) {
+	cfg := Config{}
+	cfg.Segment.InitialOffset = 1
+	store, err := newLogStore(t.TempDir(), cfg)
+	require.NoError(t, err)
+	defer func() { _ = store.Close() }()
+
+	for i := uint64(1); i <= 5; i++ {
+		require.NoError(t, store.StoreLog(&raft.Log{Index: i, Term: 1, Data: []byte{byte(i)}}))
+	}
+
+	require.ErrorIs(t, store.DeleteRange(2, 4), ErrUnsupportedDeleteRange)
+	for i := uint64(1); i <= 5; i++ {
+		var got raft.Log
+		require.NoError(t, store.GetLog(i, &got), "실패한 삭제는 아무것도 지우지 않는다")
+		require.Equal(t, []byte{byte(i)}, got.Data)
+	}
+
+	require.NoError(t, store.DeleteRange(1, 5), "클러스터를 복구할 때처럼 모두 지울 수 있다")
+	last, err := store.LastIndex()
+	require.NoError(t, err)
+	require.Zero(t, last)
+}
+
+func requireSameLogStore(t *testing.T, want raft.LogStore, got raft.LogStore) {
+	t.Helper()
+
+	wantFirst, err := want.FirstIndex()
+	require.NoError(t, err)
+	gotFirst, err := got.FirstIndex()
+	require.NoError(t, err)
+	require.Equal(t, wantFirst, gotFirst)
+
+	wantLast, err := want.LastIndex()
+	require.NoError(t, err)
+	gotLast, err := got.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, wantLast, gotLast)
+
+	for index := wantFirst; index <= wantLast+1; index++ {
+		var wantLog, gotLog raft.Log
+		wantErr := want.GetLog(index, &wantLog)
+		gotErr := got.GetLog(index, &gotLog)
+		require.Equal(t, wantErr, gotErr, "index %d", index)
+		require.Equal(t, wantLog.Index, gotLog.Index)
+		require.Equal(t, wantLog.Term, gotLog.Term)
+		require.Equal(t, wantLog.Type, gotLog.Type)
+		require.Equal(t, wantLog.Data, gotLog.Data)
+	}
+
+	if wantFirst > 0 {
+		var log raft.Log
+		require.Equal(t, raft.ErrLogNotFound, got.GetLog(wantFirst-1, &log))
+	}
+}
+
+var _ raft.SnapshotSink = (*fakeSink)(nil)
+
+type fakeSink struct {
+	bytes.Buffer
+	delay    time.Duration
+	closed   bool
+	canceled bool
+}
+
+func (s *fakeSink) Write(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.Buffer.Write(p)
+}
+
+func (s *fakeSink) ID() string { return "fake" }
+
+func (s *fakeSink) Cancel() error {
+	s.canceled = true
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	s.closed = true
+	return nil
+}
@@ -105,6 +105,13 @@ func (i *index) Write(off uint32, pos uint64) error {
 	return nil
 }
 
+// Truncate keeps only the first n entries of the index.
+func (i *index) Truncate(n uint64) {
+	if size := n * entWidth; size < i.size {
+		i.size = size
+	}
+}
+
 func (i *index) Name() string {
 	return i.file.Name()
 }
@@ -0,0 +1,214 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+// SegmentReport is what InspectSegment found in the files of a segment.
+type SegmentReport struct {
+	BaseOffset uint64
+	StorePath  string
+	IndexPath  string
+	// StoreSize and IndexSize are the sizes of the files in bytes.
+	StoreSize uint64
+	IndexSize uint64
+	// Records are the records in the store, in order, whether the index
+	// points at them or not.
+	Records []RecordReport
+	// Problems are the inconsistencies found between and within the files.
+	Problems []string
+}
+
+// NextOffset is the offset right after the segment's last record.
+func (r *SegmentReport) NextOffset() uint64 {
+	return r.BaseOffset + uint64(len(r.Records))
+}
+
+// RecordReport locates a record in a store file.
+type RecordReport struct {
+	// Position is where the record's length starts in the store.
+	Position uint64
+	// Size is the length of the encoded record, without its length.
+	Size uint64
+	// Record is the decoded record, or nil if it doesn't decode.
+	Record *pb.Record
+}
+
+// SegmentBaseOffsets returns the base offsets of the segments whose files are
+// in dir, in order.
+func SegmentBaseOffsets(dir string) ([]uint64, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseOffsets []uint64
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if ext != storeExt && ext != indexExt {
+			continue
+		}
+		off, err := strconv.ParseUint(strings.TrimSuffix(file.Name(), ext), 10, 64)
+		if err != nil {
+			continue
+		}
+		baseOffsets = append(baseOffsets, off)
+	}
+	slices.Sort(baseOffsets)
+	return slices.Compact(baseOffsets), nil
+}
+
+// MoveSegments moves the files of the segments in src into dst, which must
+// not have files of the same names, and returns their base offsets. It can
+// be run again after it stopped halfway to move the rest.
+func MoveSegments(src, dst string) ([]uint64, error) {
+	baseOffsets, err := SegmentBaseOffsets(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, baseOffset := range baseOffsets {
+		for _, ext := range []string{storeExt, indexExt} {
+			name := strconv.FormatUint(baseOffset, 10) + ext
+			if _, err := os.Lstat(filepath.Join(src, name)); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(dst, name)); err == nil {
+				return nil, fmt.Errorf("%s is in both %s and %s", name, src, dst)
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			if err := os.Rename(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return baseOffsets, nil
+}
+
+// InspectSegment reads the files of the segment at baseOffset in dir without
+// changing them, unlike opening a Log, which resizes the index, and checks
+// that the index points at every record in the store and only at them. It
+// fails only if it can't read the files; what's wrong with them is in the
+// report's Problems.
+func InspectSegment(dir string, baseOffset uint64) (*SegmentReport, error) {
+	name := filepath.Join(dir, strconv.FormatUint(baseOffset, 10))
+	r := &SegmentReport{
+		BaseOffset: baseOffset,
+		StorePath:  name + storeExt,
+		IndexPath:  name + indexExt,
+	}
+
+	store, err := readFileIfExists(r.StorePath)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		r.problemf("store file is missing")
+	}
+	index, err := readFileIfExists(r.IndexPath)
+	if err != nil {
+		return nil, err
+	}
+	if index == nil {
+		r.problemf("index file is missing")
+	}
+	r.StoreSize, r.IndexSize = uint64(len(store)), uint64(len(index))
+
+	r.scanStore(store)
+	r.checkIndex(index)
+	return r, nil
+}
+
+// scanStore reads the records of the store one after the other.
+func (r *SegmentReport) scanStore(store []byte) {
+	for pos := uint64(0); pos < uint64(len(store)); {
+		if uint64(len(store))-pos < lenWidth {
+			r.problemf("store ends with %d bytes at position %d, too few for a length", uint64(len(store))-pos, pos)
+			return
+		}
+		size := enc.Uint64(store[pos : pos+lenWidth])
+		if size > uint64(len(store))-pos-lenWidth {
+			r.problemf("record at position %d is %d bytes but the store ends %d bytes after it", pos, size, uint64(len(store))-pos-lenWidth)
+			return
+		}
+
+		rec := RecordReport{Position: pos, Size: size}
+		offset := r.NextOffset()
+		record := &pb.Record{}
+		if err := proto.Unmarshal(store[pos+lenWidth:pos+lenWidth+size], record); err != nil {
+			r.problemf("record %d at position %d doesn't decode: %v", offset, pos, err)
+		} else {
+			rec.Record = record
+			if record.GetOffset() != offset {
+				r.problemf("record %d at position %d says its offset is %d", offset, pos, record.GetOffset())
+			}
+		}
+		r.Records = append(r.Records, rec)
+		pos += lenWidth + size
+	}
+}
+
+// checkIndex compares the entries of the index with the records of the
+// store.
+func (r *SegmentReport) checkIndex(index []byte) {
+	// NOTE - 열린 로그나 제대로 닫히지 않은 로그의 인덱스 파일은 MaxIndexBytes까지 0으로 채워져 있다.
+	// 첫 엔트리는 언제나 0이므로 스토어에 뭔가 있으면 남겨 둔다.
+	minSize := len(r.Records) * entWidth
+	if minSize == 0 && r.StoreSize > 0 {
+		minSize = entWidth
+	}
+	size := len(index)
+	for size > minSize && index[size-1] == 0 {
+		size--
+	}
+	if padding := len(index) - size; padding > 0 {
+		r.problemf("index is padded with %d empty bytes; the log is open or wasn't closed cleanly", padding)
+	}
+	if size%entWidth != 0 {
+		r.problemf("index ends with a partial entry of %d bytes", size%entWidth)
+	}
+
+	entries := size / entWidth
+	for i := range min(entries, len(r.Records)) {
+		off := enc.Uint32(index[i*entWidth : i*entWidth+offWidth])
+		pos := enc.Uint64(index[i*entWidth+offWidth : (i+1)*entWidth])
+		offset := r.BaseOffset + uint64(i)
+		if off != uint32(i) {
+			r.problemf("index entry %d is for offset %d", offset, r.BaseOffset+uint64(off))
+		}
+		if want := r.Records[i].Position; pos != want {
+			r.problemf("index entry %d points at position %d but the record is at %d", offset, pos, want)
+		}
+	}
+	switch {
+	case entries > len(r.Records):
+		r.problemf("%d index entries from offset %d point past the end of the store", entries-len(r.Records), r.NextOffset())
+	case entries < len(r.Records):
+		r.problemf("%d records from offset %d aren't indexed", len(r.Records)-entries, r.BaseOffset+uint64(entries))
+	}
+}
+
+func (r *SegmentReport) problemf(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// readFileIfExists reads the file at path, opened read-only, or returns nil
+// if it doesn't exist.
+func readFileIfExists(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
@@ -0,0 +1,130 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestInspectSegment(t *testing.T) {
+	dir := t.TempDir()
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 3
+	l, err := NewLog(dir, c)
+	require.NoError(t, err)
+	for i := range 5 {
+		_, err := l.Append(&pb.Record{Value: []byte("hello world"), Term: uint64(i)})
+		require.NoError(t, err)
+	}
+
+	baseOffsets, err := SegmentBaseOffsets(dir)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 3}, baseOffsets)
+
+	r, err := InspectSegment(dir, 3)
+	require.NoError(t, err)
+	require.NotEmpty(t, r.Problems, "열린 로그의 인덱스는 최대 크기까지 늘어나 있다")
+	require.Contains(t, r.Problems[0], "padded")
+
+	require.NoError(t, l.Close())
+
+	var next uint64
+	for _, base := range baseOffsets {
+		r, err := InspectSegment(dir, base)
+		require.NoError(t, err)
+		require.Empty(t, r.Problems)
+		require.Equal(t, next, r.BaseOffset)
+		require.Equal(t, uint64(len(r.Records)*entWidth), r.IndexSize)
+
+		var pos uint64
+		for i, rec := range r.Records {
+			require.Equal(t, pos, rec.Position)
+			require.Equal(t, base+uint64(i), rec.Record.GetOffset())
+			require.Equal(t, base+uint64(i), rec.Record.GetTerm())
+			require.Equal(t, []byte("hello world"), rec.Record.GetValue())
+			pos += lenWidth + rec.Size
+		}
+		require.Equal(t, r.StoreSize, pos)
+		next = r.NextOffset()
+	}
+	require.Equal(t, uint64(5), next)
+
+	t.Run("Err/TruncatedStore", func(t *testing.T) {
+		r, err := InspectSegment(dir, 3)
+		require.NoError(t, err)
+		require.NoError(t, os.Truncate(r.StorePath, int64(r.StoreSize-1)))
+
+		r, err = InspectSegment(dir, 3)
+		require.NoError(t, err)
+		require.Len(t, r.Records, 1, "잘린 레코드 앞까지는 읽는다")
+		require.Equal(t, []string{
+			"record at position 25 is 17 bytes but the store ends 16 bytes after it",
+			"1 index entries from offset 4 point past the end of the store",
+		}, r.Problems)
+	})
+
+	t.Run("Err/Index", func(t *testing.T) {
+		r, err := InspectSegment(dir, 0)
+		require.NoError(t, err)
+
+		index, err := os.ReadFile(r.IndexPath)
+		require.NoError(t, err)
+		enc.PutUint64(index[entWidth+offWidth:], 7)
+		require.NoError(t, os.WriteFile(r.IndexPath, index[:2*entWidth], 0o644))
+
+		r, err = InspectSegment(dir, 0)
+		require.NoError(t, err)
+		require.Len(t, r.Records, 3)
+		require.Equal(t, []string{
+			"index entry 1 points at position 7 but the record is at 21",
+			"1 records from offset 2 aren't indexed",
+		}, r.Problems)
+	})
+
+	t.Run("Err/Missing", func(t *testing.T) {
+		r, err := InspectSegment(dir, 42)
+		require.NoError(t, err)
+		require.Equal(t, []string{"store file is missing", "index file is missing"}, r.Problems)
+	})
+}
+
+func TestMoveSegments(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 3
+	l, err := NewLog(src, c)
+	require.NoError(t, err)
+	for range 5 {
+		_, err := l.Append(&pb.Record{Value: []byte("hello world")})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	// NOTE - 중간에 멈춘 것처럼 세그먼트 하나의 파일을 먼저 옮겨 둔다.
+	require.NoError(t, os.Rename(filepath.Join(src, "3.store"), filepath.Join(dst, "3.store")))
+
+	baseOffsets, err := MoveSegments(src, dst)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 3}, baseOffsets)
+
+	moved, err := SegmentBaseOffsets(src)
+	require.NoError(t, err)
+	require.Empty(t, moved)
+
+	l, err = NewLog(dst, c)
+	require.NoError(t, err)
+	defer l.Close()
+	off, err := l.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), off, "옮긴 세그먼트로 로그를 연다")
+
+	require.NoError(t, os.WriteFile(filepath.Join(src, "0.index"), nil, 0o644))
+	_, err = MoveSegments(src, dst)
+	require.ErrorContains(t, err, "0.index is in both", "같은 이름의 파일을 덮어쓰지 않는다")
+}
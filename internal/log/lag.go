@@ -0,0 +1,267 @@
+package log
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+const (
+	RaftLagKind       = "raft"
+	ReplicatorLagKind = "replicator"
+)
+
+var (
+	_ raft.Transport   = (*lagTransport)(nil)
+	_ raft.WithPreVote = (*lagTransport)(nil)
+	_ raft.WithClose   = (*lagTransport)(nil)
+)
+
+var errPreVoteNotSupported = errors.New("transport doesn't support pre-vote")
+
+// lagTransport remembers the last log index each follower reported in its
+// AppendEntries responses, heartbeats included, since raft doesn't expose
+// its followers' match index. Likewise it remembers the commit index the
+// leader last sent this node, see Consumer.
+type lagTransport struct {
+	raft.Transport
+
+	mu      sync.Mutex
+	lastLog map[raft.ServerID]uint64
+
+	leaderCommit atomic.Uint64
+	consumeOnce  sync.Once
+	consumeCh    chan raft.RPC
+	closeOnce    sync.Once
+	closeCh      chan struct{}
+}
+
+func newLagTransport(t raft.Transport) *lagTransport {
+	return &lagTransport{
+		Transport: t,
+		lastLog:   make(map[raft.ServerID]uint64),
+		consumeCh: make(chan raft.RPC),
+		closeCh:   make(chan struct{}),
+	}
+}
+
+// Consumer relays the RPCs from peers to raft, noting the leader's commit
+// index on the way, which raft caps at the follower's own last index.
+func (t *lagTransport) Consumer() <-chan raft.RPC {
+	t.consumeOnce.Do(func() {
+		go t.relay(t.Transport.Consumer())
+	})
+	return t.consumeCh
+}
+
+func (t *lagTransport) relay(rpcs <-chan raft.RPC) {
+	for {
+		select {
+		case rpc := <-rpcs:
+			if req, ok := rpc.Command.(*raft.AppendEntriesRequest); ok {
+				t.leaderCommit.Store(req.LeaderCommitIndex)
+			}
+			select {
+			case t.consumeCh <- rpc:
+			case <-t.closeCh:
+				return
+			}
+		case <-t.closeCh:
+			return
+		}
+	}
+}
+
+func (t *lagTransport) AppendEntries(
+	id raft.ServerID,
+	target raft.ServerAddress,
+	args *raft.AppendEntriesRequest,
+	resp *raft.AppendEntriesResponse,
+) error {
+	if err := t.Transport.AppendEntries(id, target, args, resp); err != nil {
+		return err
+	}
+	t.observe(id, resp)
+	return nil
+}
+
+func (t *lagTransport) AppendEntriesPipeline(id raft.ServerID, target raft.ServerAddress) (raft.AppendPipeline, error) {
+	p, err := t.Transport.AppendEntriesPipeline(id, target)
+	if err != nil {
+		return nil, err
+	}
+	return newLagPipeline(p, func(resp *raft.AppendEntriesResponse) {
+		t.observe(id, resp)
+	}), nil
+}
+
+func (t *lagTransport) RequestPreVote(
+	id raft.ServerID,
+	target raft.ServerAddress,
+	args *raft.RequestPreVoteRequest,
+	resp *raft.RequestPreVoteResponse,
+) error {
+	if pv, ok := t.Transport.(raft.WithPreVote); ok {
+		return pv.RequestPreVote(id, target, args, resp)
+	}
+	return errPreVoteNotSupported
+}
+
+func (t *lagTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closeCh)
+	})
+	if c, ok := t.Transport.(raft.WithClose); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (t *lagTransport) observe(id raft.ServerID, resp *raft.AppendEntriesResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastLog[id] = resp.LastLog
+}
+
+// lag returns how many entries the follower is behind last, counting all of
+// them if it hasn't answered yet.
+func (t *lagTransport) lag(id raft.ServerID, last uint64) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last < t.lastLog[id] {
+		return 0
+	}
+	return last - t.lastLog[id]
+}
+
+// lagPipeline relays the pipeline's finished appends to raft, observing
+// each response on the way.
+type lagPipeline struct {
+	raft.AppendPipeline
+	observe func(*raft.AppendEntriesResponse)
+
+	consumeCh chan raft.AppendFuture
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func newLagPipeline(p raft.AppendPipeline, observe func(*raft.AppendEntriesResponse)) *lagPipeline {
+	lp := &lagPipeline{
+		AppendPipeline: p,
+		observe:        observe,
+		consumeCh:      make(chan raft.AppendFuture),
+		closeCh:        make(chan struct{}),
+	}
+	go lp.relay()
+	return lp
+}
+
+func (p *lagPipeline) relay() {
+	for {
+		select {
+		case f := <-p.AppendPipeline.Consumer():
+			if f.Error() == nil {
+				p.observe(f.Response())
+			}
+			select {
+			case p.consumeCh <- f:
+			case <-p.closeCh:
+				return
+			}
+		case <-p.closeCh:
+			return
+		}
+	}
+}
+
+func (p *lagPipeline) Consumer() <-chan raft.AppendFuture {
+	return p.consumeCh
+}
+
+func (p *lagPipeline) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closeCh)
+	})
+	return p.AppendPipeline.Close()
+}
+
+// ReplicationLag returns how far each follower is behind this node's log.
+// Only the leader knows; followers return nothing.
+func (l *DistributedLog) ReplicationLag() []*pb.ReplicationLag {
+	if l.raft.State() != raft.Leader {
+		return nil
+	}
+
+	future := l.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil
+	}
+
+	last := l.raft.LastIndex()
+	var lags []*pb.ReplicationLag
+	for _, srv := range future.Configuration().Servers {
+		if srv.ID == l.Config.Raft.LocalID {
+			continue
+		}
+		lags = append(lags, &pb.ReplicationLag{
+			Peer: string(srv.ID),
+			Kind: RaftLagKind,
+			Lag:  l.transport.lag(srv.ID, last),
+		})
+	}
+	return lags
+}
+
+const replicationLagMetric = "proglog/replication_lag"
+
+var _ metricproducer.Producer = (*LagProducer)(nil)
+
+// LagProducer exports the lags reported by its sources as the
+// proglog/replication_lag gauge, labeled by peer and kind. Register it with
+// metricproducer.GlobalManager() so exporters pick it up.
+type LagProducer struct {
+	sources []func() []*pb.ReplicationLag
+}
+
+func NewLagProducer(sources ...func() []*pb.ReplicationLag) *LagProducer {
+	return &LagProducer{sources: sources}
+}
+
+func (p *LagProducer) Read() []*metricdata.Metric {
+	now := time.Now()
+
+	m := &metricdata.Metric{
+		Descriptor: metricdata.Descriptor{
+			Name:        replicationLagMetric,
+			Description: "Number of entries a replica is behind",
+			Unit:        metricdata.UnitDimensionless,
+			Type:        metricdata.TypeGaugeInt64,
+			LabelKeys: []metricdata.LabelKey{
+				{Key: "peer"},
+				{Key: "kind"},
+			},
+		},
+	}
+	for _, source := range p.sources {
+		for _, lag := range source() {
+			m.TimeSeries = append(m.TimeSeries, &metricdata.TimeSeries{
+				LabelValues: []metricdata.LabelValue{
+					metricdata.NewLabelValue(lag.GetPeer()),
+					metricdata.NewLabelValue(lag.GetKind()),
+				},
+				Points:    []metricdata.Point{metricdata.NewInt64Point(now, int64(lag.GetLag()))},
+				StartTime: now,
+			})
+		}
+	}
+	return []*metricdata.Metric{m}
+}
@@ -1,22 +1,37 @@
 package log
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
-	"strings"
 	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/zrma/proglog/internal/pb"
 )
 
+// NOTE - rebaseSegment는 새 세그먼트를 rebasingDir에 쓰고, 다 쓰면 rebasedDir로 옮긴 뒤 기존 세그먼트를 지운다.
+// 세그먼트 이름이 아니라서 로그를 열 때 세그먼트로 읽지 않고, 도중에 멈췄으면 setup이 마저 정리한다.
+const (
+	rebasingDir = ".rebasing"
+	rebasedDir  = ".rebased"
+)
+
 type Log struct {
 	mu     sync.RWMutex
 	Dir    string
 	Config Config
 
+	// name labels the log's metrics, see metricsName.
+	name string
+
 	activeSegment *segment
 	segments      []*segment
 }
@@ -38,27 +53,22 @@ func NewLog(dir string, c Config) (*Log, error) {
 }
 
 func (l *Log) setup() error {
-	files, err := os.ReadDir(l.Dir)
-	if err != nil {
+	for _, dir := range []string{rebasingDir, restoringDir} {
+		if err := os.RemoveAll(filepath.Join(l.Dir, dir)); err != nil {
+			return err
+		}
+	}
+	if err := l.finishRebase(); err != nil {
 		return err
 	}
 
-	baseOffsets := make([]uint64, 0, len(files))
-	for _, file := range files {
-		offStr := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
-		off, err := strconv.ParseUint(offStr, 10, 0)
-		if err != nil {
-			continue
-		}
-		baseOffsets = append(baseOffsets, off)
+	baseOffsets, err := SegmentBaseOffsets(l.Dir)
+	if err != nil {
+		return err
 	}
-	sort.Slice(baseOffsets, func(i, j int) bool {
-		return baseOffsets[i] < baseOffsets[j]
-	})
 
-	// NOTE - baseOffsets: index, store 파일을 중복해서 담고 있으므로 2개씩 증가
-	for i := 0; i < len(baseOffsets); i += 2 {
-		if err := l.newSegment(baseOffsets[i]); err != nil {
+	for _, off := range baseOffsets {
+		if err := l.newSegment(off); err != nil {
 			return err
 		}
 	}
@@ -82,12 +92,25 @@ func (l *Log) newSegment(off uint64) error {
 }
 
 func (l *Log) Append(record *pb.Record) (uint64, error) {
+	return l.append(context.Background(), record)
+}
+
+func (l *Log) append(ctx context.Context, record *pb.Record) (off uint64, err error) {
+	defer l.recordAppendLatency(time.Now())
+
+	ctx, span := startSpan(ctx, record, "log.Append")
+	defer func() {
+		if err == nil {
+			span.SetAttributes(attribute.Int64("proglog.offset", int64(off)))
+		}
+		endSpan(span, err)
+	}()
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	if l.activeSegment.IsMaxed() {
-		off := l.activeSegment.nextOffset
-		if err := l.newSegment(off); err != nil {
+		if err := l.rollover(ctx, record); err != nil {
 			return 0, err
 		}
 	}
@@ -95,6 +118,16 @@ func (l *Log) Append(record *pb.Record) (uint64, error) {
 	return l.activeSegment.Append(record)
 }
 
+// rollover starts a new active segment after the maxed out one.
+func (l *Log) rollover(ctx context.Context, record *pb.Record) (err error) {
+	_, span := startSpan(ctx, record, "log.rollover")
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	return l.newSegment(l.activeSegment.nextOffset)
+}
+
 func (l *Log) Read(off uint64) (*pb.Record, error) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -161,7 +194,19 @@ func (l *Log) HighestOffset() (uint64, error) {
 	return off - 1, nil
 }
 
+// Truncate removes the segments whose records all have an offset at or
+// below lowest. The segment holding lowest is kept whole, so the log may
+// still start at or below lowest; LowestOffset says where.
 func (l *Log) Truncate(lowest uint64) error {
+	return l.truncatePrefix(lowest, false)
+}
+
+// truncatePrefix removes the segments whose records all have an offset at or
+// below lowest and, if exact, rewrites the segment holding lowest with
+// rebaseSegment so that the log starts right after it. That copies the rest
+// of the segment, up to MaxStoreBytes, so only a store that must not hand
+// out the removed records, like Raft's, asks for it.
+func (l *Log) truncatePrefix(lowest uint64, exact bool) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -173,31 +218,173 @@ func (l *Log) Truncate(lowest uint64) error {
 			}
 			continue
 		}
+		if exact && s.baseOffset <= lowest {
+			var err error
+			if s, err = l.rebaseSegment(s, lowest+1); err != nil {
+				return err
+			}
+		}
+		segments = append(segments, s)
+	}
+
+	return l.replaceSegments(segments, lowest+1)
+}
+
+// TruncateSuffix removes every record with an offset at or above off, so the
+// next appended record gets off.
+func (l *Log) TruncateSuffix(off uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var segments []*segment
+	for _, s := range l.segments {
+		if s.baseOffset >= off {
+			if err := s.Remove(); err != nil {
+				return err
+			}
+			continue
+		}
+		if s.nextOffset > off {
+			if err := s.Truncate(off); err != nil {
+				return err
+			}
+		}
+		segments = append(segments, s)
+	}
+
+	return l.replaceSegments(segments, off)
+}
+
+// rebaseSegment moves the records of s from off onward into a new segment
+// based at off and removes s. The new segment is written aside and only
+// moved into place once s is gone, so a crash never leaves both in the log.
+func (l *Log) rebaseSegment(s *segment, off uint64) (*segment, error) {
+	rebasing := filepath.Join(l.Dir, rebasingDir)
+	if err := os.RemoveAll(rebasing); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(rebasing, 0o755); err != nil {
+		return nil, err
+	}
+	ns, err := newSegment(rebasing, off, l.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	for cur := off; cur < s.nextOffset; cur++ {
+		record, err := s.Read(cur)
+		if err != nil {
+			return nil, errors.Join(err, ns.Close())
+		}
+		if _, err := ns.Append(record); err != nil {
+			return nil, errors.Join(err, ns.Close())
+		}
+	}
+	if err := ns.store.Flush(); err != nil {
+		return nil, errors.Join(err, ns.Close())
+	}
+	if err := ns.store.Sync(); err != nil {
+		return nil, errors.Join(err, ns.Close())
+	}
+	if err := ns.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(rebasing, filepath.Join(l.Dir, rebasedDir)); err != nil {
+		return nil, err
+	}
+	if err := syncDir(l.Dir); err != nil {
+		return nil, err
+	}
+	if err := s.Remove(); err != nil {
+		return nil, err
+	}
+	if err := l.finishRebase(); err != nil {
+		return nil, err
+	}
+	return newSegment(l.Dir, off, l.Config)
+}
+
+// finishRebase moves a segment rebaseSegment wrote into the log dir,
+// removing the segments below it first if a crash left them behind.
+func (l *Log) finishRebase() error {
+	rebased := filepath.Join(l.Dir, rebasedDir)
+	baseOffsets, err := SegmentBaseOffsets(rebased)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(baseOffsets) != 1 {
+		return fmt.Errorf("%s holds %d segments instead of 1", rebased, len(baseOffsets))
+	}
+	off := baseOffsets[0]
+
+	existing, err := SegmentBaseOffsets(l.Dir)
+	if err != nil {
+		return err
+	}
+	for _, base := range existing {
+		if base >= off {
+			break
+		}
+		for _, ext := range []string{indexExt, storeExt} {
+			name := filepath.Join(l.Dir, strconv.FormatUint(base, 10)+ext)
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	for _, ext := range []string{storeExt, indexExt} {
+		name := strconv.FormatUint(off, 10) + ext
+		if err := os.Rename(filepath.Join(rebased, name), filepath.Join(l.Dir, name)); err != nil {
+			return err
+		}
+	}
+	if err := syncDir(l.Dir); err != nil {
+		return err
+	}
+	return os.Remove(rebased)
+}
+
+// syncDir persists the entries of dir, e.g. files renamed into it.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *Log) replaceSegments(segments []*segment, nextOffset uint64) error {
+	if len(segments) == 0 {
+		s, err := newSegment(l.Dir, nextOffset, l.Config)
+		if err != nil {
+			return err
+		}
 		segments = append(segments, s)
 	}
 
 	l.segments = segments
+	l.activeSegment = segments[len(segments)-1]
 	return nil
 }
 
+// Reader reads the stores of every segment up to their sizes at the time of
+// the call; records appended afterwards are not included.
 func (l *Log) Reader() io.Reader {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
 	readers := make([]io.Reader, len(l.segments))
 	for i, segment := range l.segments {
-		readers[i] = &storeReader{segment.store, 0}
+		readers[i] = io.NewSectionReader(segment.store, 0, int64(segment.store.size))
 	}
 	return io.MultiReader(readers...)
 }
-
-type storeReader struct {
-	*store
-	off int64
-}
-
-func (s *storeReader) Read(p []byte) (n int, err error) {
-	n, err = s.ReadAt(p, s.off)
-	s.off += int64(n)
-	return
-}
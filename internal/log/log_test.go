@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -86,6 +87,10 @@ func TestLog(t *testing.T) {
 		require.Equal(t, uint64(0), off)
 
 		reader := f.log.Reader()
+
+		_, err = f.log.Append(&pb.Record{Value: []byte("appended after reader")})
+		require.NoError(t, err)
+
 		b, err := io.ReadAll(reader)
 		require.NoError(t, err)
 
@@ -144,6 +149,179 @@ func TestLog(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, uint64(2), highest)
 	})
+
+	t.Run("OK/TruncateMidSegment", func(t *testing.T) {
+		f := newFixture(t)
+
+		want := &pb.Record{
+			Value: []byte("hello world"),
+		}
+
+		for i := 0; i < 4; i++ {
+			_, err := f.log.Append(want)
+			require.NoError(t, err)
+		}
+
+		err := f.log.Truncate(2)
+		require.NoError(t, err)
+
+		lowest, err := f.log.LowestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), lowest, "Truncate는 lowest를 담은 세그먼트를 통째로 남긴다")
+
+		err = f.log.truncatePrefix(2, true)
+		require.NoError(t, err)
+
+		lowest, err = f.log.LowestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), lowest, "exact면 lowest 바로 다음부터 시작하도록 세그먼트를 다시 쓴다")
+
+		_, err = f.log.Read(2)
+		require.Error(t, err)
+
+		got, err := f.log.Read(3)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), got.GetOffset())
+
+		off, err := f.log.Append(want)
+		require.NoError(t, err)
+		require.Equal(t, uint64(4), off)
+
+		err = f.log.Truncate(10)
+		require.NoError(t, err)
+
+		off, err = f.log.Append(want)
+		require.NoError(t, err)
+		require.Equal(t, uint64(11), off, "모두 지워지면 lowest+1부터 다시 시작")
+	})
+
+	t.Run("OK/TruncateCrashed", func(t *testing.T) {
+		f := newFixture(t)
+		rebased := newFixture(t)
+
+		want := &pb.Record{
+			Value: []byte("hello world"),
+		}
+
+		for _, l := range []*Log{f.log, rebased.log} {
+			for i := 0; i < 4; i++ {
+				_, err := l.Append(want)
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, rebased.log.truncatePrefix(2, true))
+		require.NoError(t, rebased.log.Close())
+		require.NoError(t, f.log.Close())
+
+		// NOTE - 새 세그먼트를 다 쓰고 기존 세그먼트를 지우기 전에 멈춘 상태를 만든다.
+		require.NoError(t, os.Mkdir(filepath.Join(f.log.Dir, rebasedDir), 0o755))
+		for _, name := range []string{"3" + storeExt, "3" + indexExt} {
+			b, err := os.ReadFile(filepath.Join(rebased.log.Dir, name))
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(f.log.Dir, rebasedDir, name), b, 0o644))
+		}
+		require.NoError(t, os.Mkdir(filepath.Join(f.log.Dir, rebasingDir), 0o755))
+
+		log, err := NewLog(f.log.Dir, f.log.Config)
+		require.NoError(t, err)
+		defer func() { _ = log.Close() }()
+
+		lowest, err := log.LowestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), lowest, "다시 열면 멈춘 truncatePrefix를 마저 끝낸다")
+		require.Len(t, log.segments, 1, "겹치는 세그먼트를 남기지 않는다")
+
+		got, err := log.Read(3)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), got.GetOffset())
+
+		for _, dir := range []string{rebasedDir, rebasingDir} {
+			_, err := os.Stat(filepath.Join(f.log.Dir, dir))
+			require.ErrorIs(t, err, os.ErrNotExist)
+		}
+	})
+
+	t.Run("OK/TruncateSuffix", func(t *testing.T) {
+		f := newFixture(t)
+
+		want := &pb.Record{
+			Value: []byte("hello world"),
+		}
+
+		for i := 0; i < 5; i++ {
+			_, err := f.log.Append(want)
+			require.NoError(t, err)
+		}
+
+		err := f.log.TruncateSuffix(3)
+		require.NoError(t, err)
+
+		highest, err := f.log.HighestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), highest)
+
+		_, err = f.log.Read(3)
+		require.Error(t, err)
+
+		replaced := &pb.Record{
+			Value: []byte("replaced"),
+		}
+
+		off, err := f.log.Append(replaced)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), off)
+
+		require.NoError(t, f.log.Close())
+
+		log, err := NewLog(f.log.Dir, f.log.Config)
+		require.NoError(t, err)
+
+		highest, err = log.HighestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), highest)
+
+		for off := uint64(0); off < 3; off++ {
+			got, err := log.Read(off)
+			require.NoError(t, err)
+			require.Equal(t, want.GetValue(), got.GetValue())
+		}
+
+		got, err := log.Read(3)
+		require.NoError(t, err)
+		require.Equal(t, replaced.GetValue(), got.GetValue())
+
+		err = log.TruncateSuffix(0)
+		require.NoError(t, err)
+
+		off, err = log.Append(want)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), off)
+	})
+
+	t.Run("OK/StatsBytes", func(t *testing.T) {
+		f := newFixture(t)
+
+		for i := 0; i < 3; i++ {
+			_, err := f.log.Append(&pb.Record{Value: []byte("hello world")})
+			require.NoError(t, err)
+		}
+		_, err := f.log.Read(2)
+		require.NoError(t, err)
+
+		files, err := os.ReadDir(f.log.Dir)
+		require.NoError(t, err)
+		var want uint64
+		for _, file := range files {
+			fi, err := file.Info()
+			require.NoError(t, err)
+			want += uint64(fi.Size())
+		}
+
+		s := f.log.stats()
+		require.Equal(t, 2, s.segments)
+		require.Equal(t, want, s.bytes, "미리 늘려 둔 인덱스까지 디스크의 파일 크기를 센다")
+		require.Greater(t, s.bytes, 2*f.log.Config.Segment.MaxIndexBytes)
+	})
 }
 
 type fixture struct {
@@ -0,0 +1,203 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+const (
+	// MirrorSourceHeader and MirrorSourceOffsetHeader record where a
+	// mirrored record came from: the source cluster's address and the
+	// record's offset in the source log, in decimal.
+	MirrorSourceHeader       = "proglog-mirror-source"
+	MirrorSourceOffsetHeader = "proglog-mirror-source-offset"
+)
+
+// ErrMirrorGap means the source has removed the records the mirror would
+// copy next, so mirroring on would leave them out of the local log.
+var ErrMirrorGap = errors.New("source no longer has the records to mirror next")
+
+type MirrorConfig struct {
+	// SourceAddr is the RPC address of any server of the remote cluster.
+	SourceAddr string
+	// SourceDialOptions dial the remote cluster, e.g. with its own TLS
+	// credentials.
+	SourceDialOptions []grpc.DialOption
+	// Target produces into the local cluster.
+	Target pb.LogClient
+	// CheckpointFile, if set, persists the next source offset to mirror, so
+	// a restarted mirror resumes instead of copying the source again.
+	CheckpointFile string
+	// SkipGaps makes the mirror skip ahead to the source's lowest offset when
+	// the source has removed the records it would copy next, giving them up.
+	// Otherwise it keeps failing with ErrMirrorGap until an operator steps in.
+	SkipGaps bool
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// reconnects to the source. They default to 100ms and 30s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Mirror copies the log of a remote cluster into the local cluster, keeping
+// each record's key, value and headers and tagging it with its source
+// offset. Records are produced before the checkpoint is saved, so a crash in
+// between mirrors a record twice rather than losing it.
+type Mirror struct {
+	MirrorConfig
+
+	logger *zap.Logger
+
+	mu   sync.Mutex
+	next uint64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewMirror(config MirrorConfig) (*Mirror, error) {
+	m := &Mirror{
+		MirrorConfig: config,
+		logger:       zap.L().Named("mirror"),
+		done:         make(chan struct{}),
+	}
+
+	if m.CheckpointFile != "" {
+		next, err := readOffsetFile(m.CheckpointFile)
+		if err != nil {
+			return nil, err
+		}
+		m.next = next
+	}
+
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	go m.run(ctx)
+
+	return m, nil
+}
+
+// NextOffset returns the source offset the mirror copies next.
+func (m *Mirror) NextOffset() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.next
+}
+
+func (m *Mirror) run(ctx context.Context) {
+	defer close(m.done)
+
+	for attempts := 0; ; {
+		err := m.mirror(ctx, func() {
+			attempts = 0
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		attempts++
+		m.logger.Error("failed to mirror",
+			zap.String("source", m.SourceAddr),
+			zap.Uint64("next_offset", m.NextOffset()),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff(attempts, m.MinBackoff, m.MaxBackoff)):
+		}
+	}
+}
+
+func (m *Mirror) mirror(ctx context.Context, progressed func()) error {
+	cc, err := grpc.NewClient(m.SourceAddr, m.SourceDialOptions...)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+	client := pb.NewLogClient(cc)
+
+	// NOTE - 원본이 이미 지운 오프셋은 ConsumeStream이 새 레코드를 기다리듯 끝없이 기다리므로 먼저 확인한다.
+	offsets, err := client.GetOffsets(ctx, &pb.GetOffsetsRequest{})
+	if err != nil {
+		return err
+	}
+	if next, lowest := m.NextOffset(), offsets.GetLowestOffset(); next < lowest {
+		if !m.SkipGaps {
+			return fmt.Errorf("%w: next offset %d, source starts at %d", ErrMirrorGap, next, lowest)
+		}
+		if err := m.checkpoint(lowest); err != nil {
+			return err
+		}
+		m.logger.Warn("skipped records the source removed",
+			zap.String("source", m.SourceAddr),
+			zap.Uint64("from", next),
+			zap.Uint64("to", lowest-1),
+		)
+	}
+
+	stream, err := client.ConsumeStream(ctx, &pb.ConsumeRequest{Offset: m.NextOffset()})
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		source := res.GetRecord()
+		record := &pb.Record{
+			Key:     source.GetKey(),
+			Value:   source.GetValue(),
+			Type:    source.GetType(),
+			Headers: make(map[string][]byte, len(source.GetHeaders())+2),
+		}
+		for k, v := range source.GetHeaders() {
+			record.Headers[k] = v
+		}
+		record.Headers[MirrorSourceHeader] = []byte(m.SourceAddr)
+		record.Headers[MirrorSourceOffsetHeader] = strconv.AppendUint(nil, source.GetOffset(), 10)
+
+		if _, err := m.Target.Produce(ctx, &pb.ProduceRequest{Record: record}); err != nil {
+			return err
+		}
+
+		if err := m.checkpoint(source.GetOffset() + 1); err != nil {
+			return err
+		}
+		progressed()
+	}
+}
+
+// checkpoint saves next as the source offset to mirror next.
+func (m *Mirror) checkpoint(next uint64) error {
+	if m.CheckpointFile != "" {
+		if err := writeOffsetFile(m.CheckpointFile, next); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	m.next = next
+	m.mu.Unlock()
+	return nil
+}
+
+// Close stops mirroring and waits for the in-flight record to finish.
+func (m *Mirror) Close() error {
+	m.cancel()
+	<-m.done
+	return nil
+}
@@ -0,0 +1,173 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestMirror(t *testing.T) {
+	source, err := NewLog(t.TempDir(), Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = source.Close()
+	})
+	target, err := NewLog(t.TempDir(), Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = target.Close()
+	})
+
+	sourceAddr := serveLog(t, &commitLogServer{log: source})
+	targetAddr := serveLog(t, &commitLogServer{log: target})
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	cc, err := grpc.NewClient(targetAddr, dialOpts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cc.Close()
+	})
+
+	appendRecords := func(from, to int) {
+		for i := from; i < to; i++ {
+			_, err := source.Append(&pb.Record{
+				Key:     fmt.Appendf(nil, "key-%d", i),
+				Value:   fmt.Appendf(nil, "value-%d", i),
+				Headers: map[string][]byte{"trace": fmt.Appendf(nil, "trace-%d", i)},
+			})
+			require.NoError(t, err)
+		}
+	}
+	waitMirrored := func(want uint64) {
+		require.Eventually(t, func() bool {
+			highest, _ := target.HighestOffset()
+			off, _ := target.LowestOffset()
+			return highest-off+1 == want
+		}, 3*time.Second, 10*time.Millisecond)
+	}
+
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+	newMirror := func() *Mirror {
+		m, err := NewMirror(MirrorConfig{
+			SourceAddr:        sourceAddr,
+			SourceDialOptions: dialOpts,
+			Target:            pb.NewLogClient(cc),
+			CheckpointFile:    checkpoint,
+			MinBackoff:        time.Millisecond,
+		})
+		require.NoError(t, err)
+		return m
+	}
+
+	appendRecords(0, 3)
+	m := newMirror()
+	waitMirrored(3)
+	require.Eventually(t, func() bool {
+		return m.NextOffset() == 3
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, m.Close())
+
+	for off := range uint64(3) {
+		record, err := target.Read(off)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Appendf(nil, "key-%d", off), record.GetKey())
+		require.Equal(t, fmt.Appendf(nil, "value-%d", off), record.GetValue())
+		require.Equal(t, fmt.Appendf(nil, "trace-%d", off), record.GetHeaders()["trace"], "원본 헤더를 유지한다")
+		require.Equal(t, []byte(sourceAddr), record.GetHeaders()[MirrorSourceHeader])
+		require.Equal(t, fmt.Appendf(nil, "%d", off), record.GetHeaders()[MirrorSourceOffsetHeader])
+	}
+
+	appendRecords(3, 5)
+	m = newMirror()
+	t.Cleanup(func() {
+		_ = m.Close()
+	})
+	require.Equal(t, uint64(3), m.NextOffset(), "체크포인트부터 이어서 미러링한다")
+	waitMirrored(5)
+
+	time.Sleep(50 * time.Millisecond)
+	highest, err := target.HighestOffset()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), highest, "이미 미러링한 레코드는 다시 쓰지 않는다")
+
+	record, err := target.Read(4)
+	require.NoError(t, err)
+	require.Equal(t, []byte("4"), record.GetHeaders()[MirrorSourceOffsetHeader])
+}
+
+func TestMirror_Gap(t *testing.T) {
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 2
+	source, err := NewLog(t.TempDir(), c)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = source.Close()
+	})
+	target, err := NewLog(t.TempDir(), Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = target.Close()
+	})
+
+	// NOTE - 미러가 아직 복사하지 않은 첫 세그먼트를 원본에서 지운다.
+	for i := range 5 {
+		_, err := source.Append(&pb.Record{Value: fmt.Appendf(nil, "value-%d", i)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, source.Truncate(1))
+
+	sourceAddr := serveLog(t, &commitLogServer{log: source})
+	targetAddr := serveLog(t, &commitLogServer{log: target})
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	cc, err := grpc.NewClient(targetAddr, dialOpts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cc.Close()
+	})
+
+	config := MirrorConfig{
+		SourceAddr:        sourceAddr,
+		SourceDialOptions: dialOpts,
+		Target:            pb.NewLogClient(cc),
+		CheckpointFile:    filepath.Join(t.TempDir(), "checkpoint"),
+		MinBackoff:        time.Millisecond,
+	}
+
+	t.Run("Err", func(t *testing.T) {
+		m := &Mirror{MirrorConfig: config, logger: zap.NewNop()}
+		err := m.mirror(context.Background(), func() {})
+		require.ErrorIs(t, err, ErrMirrorGap, "원본이 지운 레코드를 기다리며 멈추지 않는다")
+		require.Zero(t, m.NextOffset())
+	})
+
+	t.Run("OK/SkipGaps", func(t *testing.T) {
+		config.SkipGaps = true
+		m, err := NewMirror(config)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = m.Close()
+		})
+
+		require.Eventually(t, func() bool {
+			return m.NextOffset() == 5
+		}, 3*time.Second, 10*time.Millisecond, "원본의 가장 낮은 오프셋부터 이어서 미러링한다")
+
+		record, err := target.Read(0)
+		require.NoError(t, err)
+		require.Equal(t, []byte("2"), record.GetHeaders()[MirrorSourceOffsetHeader])
+
+		next, err := readOffsetFile(config.CheckpointFile)
+		require.NoError(t, err)
+		require.Equal(t, uint64(5), next)
+	})
+}
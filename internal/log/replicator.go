@@ -2,10 +2,18 @@ package log
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/zrma/proglog/internal/pb"
 )
@@ -13,15 +21,95 @@ import (
 type Replicator struct {
 	DialOptions []grpc.DialOption
 	LocalServer pb.LogClient
+	// LocalID is the id peers know this node by. Records that originated
+	// here are never copied back.
+	LocalID string
+
+	// ProgressDir, if set, persists the next offset to consume from each
+	// peer and the next offset to apply from each origin, so replication
+	// resumes where it left off after a restart instead of copying the
+	// peers' logs from the start again.
+	ProgressDir string
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// reconnects to a peer. They default to 100ms and 30s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
 
 	logger *zap.Logger
 
 	mu      sync.Mutex
-	servers map[string]chan struct{}
+	servers map[string]*peer
 	closed  bool
 	close   chan struct{}
+	wg      sync.WaitGroup
+
+	// NOTE - 여러 피어에서 같은 origin의 레코드가 동시에 들어오므로 적용은 직렬화한다.
+	applyMu sync.Mutex
+	applied map[string]uint64
+}
+
+type PeerStatus string
+
+const (
+	PeerConnecting  PeerStatus = "connecting"
+	PeerReplicating PeerStatus = "replicating"
+	PeerBackoff     PeerStatus = "backoff"
+	// PeerGap means the peer removed records before they were copied, so
+	// replication from it stopped with ErrReplicationGap.
+	PeerGap PeerStatus = "gap"
+)
+
+// ErrReplicationGap means a peer has removed the records a Replicator would
+// copy from it next, so they can't be replicated from that peer anymore.
+var ErrReplicationGap = errors.New("peer no longer has the records to replicate next")
+
+// PeerState is a snapshot of the replication from a single peer.
+type PeerState struct {
+	Name   string
+	Addr   string
+	Status PeerStatus
+	// NextOffset is the peer's offset replication resumes from.
+	NextOffset uint64
+	// Lag is how many of the peer's records are left to copy, as of the
+	// last record received.
+	Lag uint64
+	// Attempts counts the consecutive failed attempts since the last
+	// record was replicated.
+	Attempts  int
+	LastError error
+}
+
+type peer struct {
+	leave chan struct{}
+
+	mu    sync.Mutex
+	state PeerState
+}
+
+func (p *peer) State() PeerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state
+}
+
+func (p *peer) update(fn func(*PeerState)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fn(&p.state)
 }
 
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+
+	offsetFileWidth = 8
+
+	peerProgressDir   = "peers"
+	originProgressDir = "origins"
+)
+
 func (r *Replicator) Join(name, addr string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -37,66 +125,319 @@ func (r *Replicator) Join(name, addr string) error {
 		return nil
 	}
 
-	r.servers[name] = make(chan struct{})
+	next, err := r.loadProgress(peerProgressDir, name)
+	if err != nil {
+		return err
+	}
+
+	p := &peer{
+		leave: make(chan struct{}),
+		state: PeerState{
+			Name:       name,
+			Addr:       addr,
+			Status:     PeerConnecting,
+			NextOffset: next,
+		},
+	}
+	r.servers[name] = p
 
-	go r.replicateFrom(addr, r.servers[name])
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.replicateFrom(p)
+	}()
 
 	return nil
 }
 
-func (r *Replicator) replicateFrom(addr string, leave chan struct{}) {
-	cc, err := grpc.NewClient(addr, r.DialOptions...)
-	if err != nil {
-		r.logError(err, "failed to dial", addr)
-		return
-	}
-	defer cc.Close()
+// Peers returns the state of replication from every joined peer.
+func (r *Replicator) Peers() []PeerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	client := pb.NewLogClient(cc)
+	states := make([]PeerState, 0, len(r.servers))
+	for _, p := range r.servers {
+		states = append(states, p.State())
+	}
+	return states
+}
 
-	ctx := context.Background()
-	stream, err := client.ConsumeStream(ctx, &pb.ConsumeRequest{Offset: 0})
-	if err != nil {
-		r.logError(err, "failed to consume", addr)
-		return
+// ReplicationLag reports the lag of every joined peer.
+func (r *Replicator) ReplicationLag() []*pb.ReplicationLag {
+	peers := r.Peers()
+	lags := make([]*pb.ReplicationLag, 0, len(peers))
+	for _, p := range peers {
+		lags = append(lags, &pb.ReplicationLag{
+			Peer: p.Name,
+			Kind: ReplicatorLagKind,
+			Lag:  p.Lag,
+		})
 	}
+	return lags
+}
 
-	records := make(chan *pb.Record)
+// replicateFrom copies the peer's records into the local server until the
+// peer leaves or the replicator closes, reconnecting with backoff whenever
+// the stream breaks.
+func (r *Replicator) replicateFrom(p *peer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		for {
-			res, err := stream.Recv()
-			if err != nil {
-				r.logError(err, "failed to receive", addr)
-				return
-			}
-			records <- res.Record
+		select {
+		case <-r.close:
+		case <-p.leave:
 		}
+		cancel()
 	}()
 
 	for {
-		select {
-		case <-r.close:
+		err := r.replicateOnce(ctx, p)
+		if ctx.Err() != nil {
 			return
-		case <-leave:
+		}
+
+		state := p.State()
+		r.logError(err, "failed to replicate", state.Addr)
+
+		// NOTE - 피어가 지운 레코드는 다시 생기지 않으므로 재시도하지 않고 멈춘 상태와 지연으로 알린다.
+		if errors.Is(err, ErrReplicationGap) {
+			p.update(func(s *PeerState) {
+				s.Status = PeerGap
+				s.LastError = err
+			})
+			return
+		}
+
+		attempts := state.Attempts + 1
+		p.update(func(s *PeerState) {
+			s.Status = PeerBackoff
+			s.Attempts = attempts
+			s.LastError = err
+		})
+
+		select {
+		case <-ctx.Done():
 			return
-		case record := <-records:
-			_, err := r.LocalServer.Produce(ctx, &pb.ProduceRequest{Record: record})
-			if err != nil {
-				r.logError(err, "failed to produce", addr)
-				return
-			}
+		case <-time.After(r.backoff(attempts)):
 		}
 	}
 }
 
+func (r *Replicator) replicateOnce(ctx context.Context, p *peer) error {
+	state := p.State()
+	p.update(func(s *PeerState) {
+		s.Status = PeerConnecting
+	})
+
+	cc, err := grpc.NewClient(state.Addr, r.DialOptions...)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	client := pb.NewLogClient(cc)
+
+	// NOTE - 피어가 이미 지운 오프셋은 ConsumeStream이 새 레코드를 기다리듯 끝없이 기다리므로 먼저 확인한다.
+	offsets, err := client.GetOffsets(ctx, &pb.GetOffsetsRequest{})
+	if err != nil {
+		return err
+	}
+	if lowest := offsets.GetLowestOffset(); state.NextOffset < lowest {
+		p.update(func(s *PeerState) {
+			s.Lag = offsets.GetHighWatermark() - state.NextOffset
+		})
+		return fmt.Errorf("%w: next offset %d, peer starts at %d", ErrReplicationGap, state.NextOffset, lowest)
+	}
+
+	stream, err := client.ConsumeStream(ctx, &pb.ConsumeRequest{Offset: state.NextOffset})
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		record := res.GetRecord()
+		if err := r.apply(ctx, state.Name, record); err != nil {
+			return err
+		}
+
+		next := record.GetOffset() + 1
+		if err := r.saveProgress(peerProgressDir, state.Name, next); err != nil {
+			return err
+		}
+		lag := uint64(0)
+		if hw := res.GetHighWatermark(); hw > next {
+			lag = hw - next
+		}
+		p.update(func(s *PeerState) {
+			s.Status = PeerReplicating
+			s.NextOffset = next
+			s.Lag = lag
+			s.Attempts = 0
+			s.LastError = nil
+		})
+	}
+}
+
+// apply produces a record copied from peer into the local server, tagged
+// with where it was first written, unless it originated here or was already
+// applied through another peer. Every node appends an origin's records in
+// the origin's order, so a per-origin watermark is enough to tell.
+func (r *Replicator) apply(ctx context.Context, peer string, record *pb.Record) error {
+	origin, originOffset := record.GetOrigin(), record.GetOffset()
+	if origin != "" {
+		originOffset = record.GetOriginOffset()
+	} else {
+		origin = peer
+	}
+	if origin == r.LocalID {
+		return nil
+	}
+	if err := checkSourceName(origin); err != nil {
+		// NOTE - 피어 로그에 남은 레코드는 바뀌지 않으므로 에러를 내면 이 피어에서 복제가 영영 멈춘다.
+		r.logger.Warn("skipping record with invalid origin",
+			zap.String("peer", peer),
+			zap.Uint64("offset", record.GetOffset()),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	next, ok := r.applied[origin]
+	if !ok {
+		var err error
+		next, err = r.loadProgress(originProgressDir, origin)
+		if err != nil {
+			return err
+		}
+	}
+	if originOffset < next {
+		return nil
+	}
+
+	record = proto.Clone(record).(*pb.Record)
+	record.Origin = origin
+	record.OriginOffset = originOffset
+	if _, err := r.LocalServer.Produce(ctx, &pb.ProduceRequest{Record: record}); err != nil {
+		return err
+	}
+
+	if err := r.saveProgress(originProgressDir, origin, originOffset+1); err != nil {
+		return err
+	}
+	r.applied[origin] = originOffset + 1
+	return nil
+}
+
+func (r *Replicator) backoff(attempts int) time.Duration {
+	return backoff(attempts, r.MinBackoff, r.MaxBackoff)
+}
+
+// backoff returns the delay before the given reconnect attempt: exponential
+// in the attempt, capped at maxBackoff, with jitter so peers that failed
+// together don't reconnect together.
+func backoff(attempts int, minBackoff, maxBackoff time.Duration) time.Duration {
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxBackoff)
+
+	return d/2 + rand.N(d/2+1)
+}
+
+func (r *Replicator) loadProgress(dir, name string) (uint64, error) {
+	if r.ProgressDir == "" {
+		return 0, nil
+	}
+	path, err := r.progressPath(dir, name)
+	if err != nil {
+		return 0, err
+	}
+	return readOffsetFile(path)
+}
+
+func (r *Replicator) saveProgress(dir, name string, next uint64) error {
+	if r.ProgressDir == "" {
+		return nil
+	}
+	path, err := r.progressPath(dir, name)
+	if err != nil {
+		return err
+	}
+	return writeOffsetFile(path, next)
+}
+
+// progressPath returns the file under dir of ProgressDir keeping the progress
+// of the peer or origin name. Names come from membership and from records,
+// so they're escaped to always stay a single file inside dir.
+func (r *Replicator) progressPath(dir, name string) (string, error) {
+	if err := checkSourceName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(r.ProgressDir, dir, url.PathEscape(name)), nil
+}
+
+// checkSourceName rejects the names of peers and origins that can't name a
+// progress file even escaped.
+func checkSourceName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("invalid replication source name %q", name)
+	}
+	return nil
+}
+
+// readOffsetFile reads an offset written by writeOffsetFile, or 0 if there
+// is none yet.
+func readOffsetFile(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != offsetFileWidth {
+		return 0, fmt.Errorf("corrupt offset file %s", path)
+	}
+	return enc.Uint64(b), nil
+}
+
+// NOTE - 임시 파일에 쓴 뒤 rename 하므로 중간에 죽어도 이전 값이 남는다.
+func writeOffsetFile(path string, off uint64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, enc.AppendUint64(nil, off), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
 func (r *Replicator) Leave(name string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	r.init()
 
-	if ch, ok := r.servers[name]; ok {
-		close(ch)
+	if p, ok := r.servers[name]; ok {
+		close(p.leave)
 		delete(r.servers, name)
 	}
 
@@ -108,11 +449,14 @@ func (r *Replicator) init() {
 		r.logger = zap.L().Named("replicator")
 	}
 	if r.servers == nil {
-		r.servers = make(map[string]chan struct{})
+		r.servers = make(map[string]*peer)
 	}
 	if r.close == nil {
 		r.close = make(chan struct{})
 	}
+	if r.applied == nil {
+		r.applied = make(map[string]uint64)
+	}
 }
 
 func (r *Replicator) Close() error {
@@ -128,6 +472,9 @@ func (r *Replicator) Close() error {
 	r.closed = true
 	close(r.close)
 
+	// NOTE - 닫힌 뒤에는 진행 상황을 더 쓰지 않도록 복제 고루틴이 끝날 때까지 기다린다.
+	r.wg.Wait()
+
 	return nil
 }
 
@@ -0,0 +1,398 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestReplicator_ResumeWithBackoff(t *testing.T) {
+	remote := &flakyLogServer{failAfter: 3}
+	for i := range 10 {
+		remote.append(fmt.Sprintf("record-%d", i))
+	}
+	addr := serveLog(t, remote)
+
+	progressDir := t.TempDir()
+	local := &recordingLogClient{}
+	r := &Replicator{
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		LocalServer: local,
+		ProgressDir: progressDir,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+	require.NoError(t, r.Join("remote", addr))
+
+	require.Eventually(t, func() bool {
+		peers := r.Peers()
+		return len(peers) == 1 && peers[0].NextOffset == 10
+	}, 3*time.Second, 10*time.Millisecond, "스트림이 끊겨도 다시 연결해서 이어받아야 한다")
+
+	for i, value := range local.values() {
+		require.Equal(t, fmt.Sprintf("record-%d", i), value, "끊긴 지점부터 중복 없이 이어받는다")
+	}
+
+	peers := r.Peers()
+	require.Len(t, peers, 1)
+	require.Equal(t, "remote", peers[0].Name)
+	require.Equal(t, uint64(10), peers[0].NextOffset)
+	require.Equal(t, []*pb.ReplicationLag{{Peer: "remote", Kind: ReplicatorLagKind, Lag: 0}}, r.ReplicationLag())
+	require.NoError(t, r.Close())
+
+	remote.append("record-10")
+	remote.append("record-11")
+
+	restarted := &recordingLogClient{}
+	r = &Replicator{
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		LocalServer: restarted,
+		ProgressDir: progressDir,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	require.NoError(t, r.Join("remote", addr))
+
+	require.Eventually(t, func() bool {
+		return len(restarted.values()) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"record-10", "record-11"}, restarted.values(), "재시작 후에는 저장된 진행 상황부터 이어받는다")
+}
+
+func TestReplicator_Gap(t *testing.T) {
+	c := Config{}
+	c.Segment.MaxIndexBytes = entWidth * 2
+	remote, err := NewLog(t.TempDir(), c)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = remote.Close()
+	})
+
+	// NOTE - 복제하기 전에 피어가 첫 세그먼트를 지운다.
+	for i := range 5 {
+		_, err := remote.Append(&pb.Record{Value: fmt.Appendf(nil, "record-%d", i)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, remote.Truncate(1))
+	addr := serveLog(t, &commitLogServer{log: remote})
+
+	local := &recordingLogClient{}
+	r := &Replicator{
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		LocalServer: local,
+		MinBackoff:  time.Millisecond,
+	}
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	require.NoError(t, r.Join("remote", addr))
+
+	require.Eventually(t, func() bool {
+		peers := r.Peers()
+		return len(peers) == 1 && peers[0].Status == PeerGap
+	}, 3*time.Second, 10*time.Millisecond, "피어가 지운 레코드를 기다리며 멈추지 않는다")
+
+	peers := r.Peers()
+	require.ErrorIs(t, peers[0].LastError, ErrReplicationGap)
+	require.Zero(t, peers[0].NextOffset)
+	require.Empty(t, local.values())
+	require.Equal(t, []*pb.ReplicationLag{{Peer: "remote", Kind: ReplicatorLagKind, Lag: 5}}, r.ReplicationLag(), "복제하지 못한 레코드를 지연으로 알린다")
+}
+
+func TestReplicator_NoLoops(t *testing.T) {
+	const nodeCount = 3
+	type node struct {
+		id   string
+		log  *Log
+		addr string
+	}
+
+	var nodes []node
+	for i := range nodeCount {
+		l, err := NewLog(t.TempDir(), Config{})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = l.Close()
+		})
+		nodes = append(nodes, node{
+			id:   fmt.Sprintf("node-%d", i),
+			log:  l,
+			addr: serveLog(t, &commitLogServer{log: l}),
+		})
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	for _, n := range nodes {
+		cc, err := grpc.NewClient(n.addr, dialOpts...)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = cc.Close()
+		})
+
+		r := &Replicator{
+			DialOptions: dialOpts,
+			LocalServer: pb.NewLogClient(cc),
+			LocalID:     n.id,
+			MinBackoff:  time.Millisecond,
+		}
+		t.Cleanup(func() {
+			_ = r.Close()
+		})
+		for _, peer := range nodes {
+			if peer.id != n.id {
+				require.NoError(t, r.Join(peer.id, peer.addr))
+			}
+		}
+	}
+
+	for _, n := range nodes {
+		_, err := n.log.Append(&pb.Record{Value: []byte(n.id)})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		for _, n := range nodes {
+			if highest, _ := n.log.HighestOffset(); highest != nodeCount-1 {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// NOTE - 레코드가 노드 사이를 계속 오가며 불어나지 않는지 확인한다.
+	time.Sleep(200 * time.Millisecond)
+	for _, n := range nodes {
+		highest, err := n.log.HighestOffset()
+		require.NoError(t, err)
+		require.Equal(t, uint64(nodeCount-1), highest, "각 레코드는 노드마다 한 번만 적용되어야 한다")
+
+		origins := map[string]bool{}
+		for off := uint64(0); off <= highest; off++ {
+			record, err := n.log.Read(off)
+			require.NoError(t, err)
+			if record.GetOrigin() == "" {
+				require.Equal(t, n.id, string(record.GetValue()))
+				origins[n.id] = true
+				continue
+			}
+			require.Equal(t, record.GetOrigin(), string(record.GetValue()))
+			origins[record.GetOrigin()] = true
+		}
+		require.Len(t, origins, nodeCount)
+	}
+}
+
+func TestReplicator_Backoff(t *testing.T) {
+	r := &Replicator{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	}
+
+	for attempts, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		5:  time.Second,
+		64: time.Second,
+	} {
+		for range 100 {
+			got := r.backoff(attempts)
+			require.GreaterOrEqual(t, got, want/2)
+			require.LessOrEqual(t, got, want)
+		}
+	}
+}
+
+func TestReplicator_ProgressPath(t *testing.T) {
+	progressDir := t.TempDir()
+	r := &Replicator{ProgressDir: progressDir}
+
+	for _, name := range []string{"node-1", "../../x", "/etc/passwd", `..\x`, "a/../.."} {
+		require.NoError(t, r.saveProgress(originProgressDir, name, 7))
+		path, err := r.progressPath(originProgressDir, name)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(progressDir, originProgressDir), filepath.Dir(path), "이름이 무엇이든 진행 상황 디렉터리 안의 파일 하나에 쓴다")
+
+		next, err := r.loadProgress(originProgressDir, name)
+		require.NoError(t, err)
+		require.Equal(t, uint64(7), next)
+	}
+
+	for _, name := range []string{"", ".", ".."} {
+		require.Error(t, r.saveProgress(originProgressDir, name, 7))
+	}
+
+	r.init()
+	require.NoError(t, r.apply(context.Background(), "node-1", &pb.Record{Origin: "..", OriginOffset: 1}), "잘못된 origin의 레코드는 건너뛴다")
+}
+
+// flakyLogServer streams its records but breaks every stream after
+// failAfter records, like a peer behind a flaky network.
+type flakyLogServer struct {
+	pb.UnimplementedLogServer
+	failAfter int
+
+	mu      sync.Mutex
+	records []*pb.Record
+}
+
+func (s *flakyLogServer) append(value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, &pb.Record{
+		Value:  []byte(value),
+		Offset: uint64(len(s.records)),
+	})
+}
+
+func (s *flakyLogServer) record(off uint64) (*pb.ConsumeResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if off >= uint64(len(s.records)) {
+		return nil, false
+	}
+	return &pb.ConsumeResponse{
+		Record:        s.records[off],
+		HighWatermark: uint64(len(s.records)),
+	}, true
+}
+
+func (s *flakyLogServer) GetOffsets(context.Context, *pb.GetOffsetsRequest) (*pb.GetOffsetsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &pb.GetOffsetsResponse{HighWatermark: uint64(len(s.records))}, nil
+}
+
+func (s *flakyLogServer) ConsumeStream(req *pb.ConsumeRequest, stream pb.Log_ConsumeStreamServer) error {
+	off := req.GetOffset()
+	for sent := 0; ; {
+		if sent == s.failAfter {
+			return status.Error(codes.Unavailable, "connection reset")
+		}
+
+		res, ok := s.record(off)
+		if !ok {
+			select {
+			case <-stream.Context().Done():
+				return nil
+			case <-time.After(time.Millisecond):
+				continue
+			}
+		}
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+		off++
+		sent++
+	}
+}
+
+// commitLogServer serves a Log the way the Log service does, enough for
+// replicators to produce into and consume from.
+type commitLogServer struct {
+	pb.UnimplementedLogServer
+	log *Log
+}
+
+func (s *commitLogServer) Produce(_ context.Context, req *pb.ProduceRequest) (*pb.ProduceResponse, error) {
+	off, err := s.log.Append(req.GetRecord())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ProduceResponse{Offset: off}, nil
+}
+
+func (s *commitLogServer) GetOffsets(context.Context, *pb.GetOffsetsRequest) (*pb.GetOffsetsResponse, error) {
+	lowest, err := s.log.LowestOffset()
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.GetOffsetsResponse{LowestOffset: lowest, HighWatermark: lowest}
+	if highest, err := s.log.HighestOffset(); err == nil {
+		if _, err := s.log.Read(highest); err == nil {
+			res.HighWatermark = highest + 1
+		}
+	}
+	return res, nil
+}
+
+func (s *commitLogServer) ConsumeStream(req *pb.ConsumeRequest, stream pb.Log_ConsumeStreamServer) error {
+	off := req.GetOffset()
+	for {
+		record, err := s.log.Read(off)
+		if err != nil {
+			select {
+			case <-stream.Context().Done():
+				return nil
+			case <-time.After(time.Millisecond):
+				continue
+			}
+		}
+
+		if err := stream.Send(&pb.ConsumeResponse{Record: record}); err != nil {
+			return err
+		}
+		off++
+	}
+}
+
+type recordingLogClient struct {
+	pb.LogClient
+
+	mu      sync.Mutex
+	records []*pb.Record
+}
+
+func (c *recordingLogClient) Produce(_ context.Context, req *pb.ProduceRequest, _ ...grpc.CallOption) (*pb.ProduceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.records = append(c.records, req.GetRecord())
+	return &pb.ProduceResponse{Offset: uint64(len(c.records) - 1)}, nil
+}
+
+func (c *recordingLogClient) values() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	values := make([]string, 0, len(c.records))
+	for _, record := range c.records {
+		values = append(values, string(record.GetValue()))
+	}
+	return values
+}
+
+func serveLog(t *testing.T, svc pb.LogServer) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	svr := grpc.NewServer()
+	pb.RegisterLogServer(svr, svc)
+	go func() {
+		_ = svr.Serve(ln)
+	}()
+	t.Cleanup(svr.Stop)
+
+	return ln.Addr().String()
+}
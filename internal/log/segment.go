@@ -106,6 +106,27 @@ func (s *segment) Read(offset uint64) (*pb.Record, error) {
 	return record, err
 }
 
+// Truncate drops every record of the segment at or above offset.
+func (s *segment) Truncate(offset uint64) error {
+	if offset >= s.nextOffset {
+		return nil
+	}
+
+	rel := offset - s.baseOffset
+	_, pos, err := s.index.Read(int64(rel))
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.Truncate(pos); err != nil {
+		return err
+	}
+	s.index.Truncate(rel)
+
+	s.nextOffset = offset
+	return nil
+}
+
 func (s *segment) IsMaxed() bool {
 	return s.store.size >= s.config.Segment.MaxStoreBytes ||
 		s.index.size+entWidth > s.config.Segment.MaxIndexBytes
@@ -0,0 +1,346 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/hashicorp/raft"
+)
+
+// NOTE - 스냅샷은 레코드를 다시 인코딩하지 않고 세그먼트 파일을 그대로 복사한다.
+//
+//	magic | count | count * (baseOffset, storeSize, indexSize) | store, index, store, index, ...
+var snapshotMagic = []byte("PLS1")
+
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+const (
+	// maxSnapshotSegments bounds the segment count a manifest may claim, so a
+	// corrupt one can't make readManifest allocate gigabytes.
+	maxSnapshotSegments = 1 << 20
+	// maxSnapshotRecordBytes is how far a store may grow past MaxStoreBytes:
+	// a segment rolls over only after the append that maxes it, and gRPC
+	// limits a produced record to 4MiB by default.
+	maxSnapshotRecordBytes = 4 << 20
+	// restoringDir holds the segment files of a snapshot until they're
+	// checked; it isn't a segment name, so opening the log ignores it.
+	restoringDir = ".restoring"
+)
+
+type manifestEntry struct {
+	BaseOffset uint64
+	StoreSize  uint64
+	IndexSize  uint64
+}
+
+const manifestEntryWidth = 3 * 8
+
+type manifest []manifestEntry
+
+func (m manifest) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, len(snapshotMagic)+offWidth+len(m)*manifestEntryWidth)
+	buf = append(buf, snapshotMagic...)
+	buf = enc.AppendUint32(buf, uint32(len(m)))
+	for _, e := range m {
+		buf = enc.AppendUint64(buf, e.BaseOffset)
+		buf = enc.AppendUint64(buf, e.StoreSize)
+		buf = enc.AppendUint64(buf, e.IndexSize)
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+func readManifest(r io.Reader) (manifest, error) {
+	head := make([]byte, len(snapshotMagic)+offWidth)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, snapshotReadErr(err)
+	}
+	if !bytes.Equal(head[:len(snapshotMagic)], snapshotMagic) {
+		return nil, ErrInvalidSnapshot
+	}
+
+	count := enc.Uint32(head[len(snapshotMagic):])
+	if count == 0 || count > maxSnapshotSegments {
+		return nil, fmt.Errorf("%w: %d segments", ErrInvalidSnapshot, count)
+	}
+
+	b := make([]byte, manifestEntryWidth)
+	m := make(manifest, 0, count)
+	for range count {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, snapshotReadErr(err)
+		}
+		m = append(m, manifestEntry{
+			BaseOffset: enc.Uint64(b[0:8]),
+			StoreSize:  enc.Uint64(b[8:16]),
+			IndexSize:  enc.Uint64(b[16:24]),
+		})
+	}
+	return m, nil
+}
+
+// segmentView is a read-only view of a segment fixed at the moment it was
+// taken. It holds its own handle on the store file and a copy of the index,
+// so the segment may keep growing, be closed or be removed while the view is
+// read.
+type segmentView struct {
+	baseOffset uint64
+	store      *os.File
+	storeSize  uint64
+	index      []byte
+}
+
+func (s *segment) view() (*segmentView, error) {
+	if err := s.store.Flush(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(s.store.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	return &segmentView{
+		baseOffset: s.baseOffset,
+		store:      f,
+		storeSize:  s.store.size,
+		index:      bytes.Clone(s.index.mmap[:s.index.size]),
+	}, nil
+}
+
+func (v *segmentView) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.Copy(w, io.NewSectionReader(v.store, 0, int64(v.storeSize)))
+	if err != nil {
+		return n, err
+	}
+
+	m, err := w.Write(v.index)
+	return n + int64(m), err
+}
+
+func (v *segmentView) Close() error {
+	return v.store.Close()
+}
+
+// snapshot returns views of every segment taken at a single point in the
+// log, i.e. between two appends.
+func (l *Log) snapshot() ([]*segmentView, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	views := make([]*segmentView, 0, len(l.segments))
+	for _, s := range l.segments {
+		v, err := s.view()
+		if err != nil {
+			for _, v := range views {
+				_ = v.Close()
+			}
+			return nil, err
+		}
+		views = append(views, v)
+	}
+	return views, nil
+}
+
+// restore replaces every segment of the log with the segment files read
+// from r, laid out as described by m. The files are written to a staging dir
+// and checked first, so an invalid snapshot leaves the log as it was.
+func (l *Log) restore(m manifest, r io.Reader) error {
+	staging := filepath.Join(l.Dir, restoringDir)
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	if err := os.Mkdir(staging, 0o755); err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(staging) }()
+
+	if err := l.stageSnapshot(staging, m, r); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, s := range l.segments {
+		if err := s.Remove(); err != nil {
+			return err
+		}
+	}
+	l.segments = nil
+	l.activeSegment = nil
+
+	for _, e := range m {
+		for _, ext := range []string{storeExt, indexExt} {
+			name := strconv.FormatUint(e.BaseOffset, 10) + ext
+			if err := os.Rename(filepath.Join(staging, name), filepath.Join(l.Dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := syncDir(l.Dir); err != nil {
+		return err
+	}
+
+	return l.setup()
+}
+
+// stageSnapshot writes the segment files read from r to dir, checking that
+// their sizes fit the log's config and that their records follow each other.
+func (l *Log) stageSnapshot(dir string, m manifest, r io.Reader) error {
+	next := m[0].BaseOffset
+	for i, e := range m {
+		if e.BaseOffset != next {
+			return fmt.Errorf("%w: segment %d starts at offset %d instead of %d", ErrInvalidSnapshot, i, e.BaseOffset, next)
+		}
+		if e.StoreSize > l.Config.Segment.MaxStoreBytes+maxSnapshotRecordBytes || e.IndexSize > l.Config.Segment.MaxIndexBytes {
+			return fmt.Errorf("%w: segment %d has a %d byte store and a %d byte index", ErrInvalidSnapshot, e.BaseOffset, e.StoreSize, e.IndexSize)
+		}
+
+		name := filepath.Join(dir, strconv.FormatUint(e.BaseOffset, 10))
+		if err := writeFileN(name+storeExt, r, e.StoreSize); err != nil {
+			return snapshotReadErr(err)
+		}
+		if err := writeFileN(name+indexExt, r, e.IndexSize); err != nil {
+			return snapshotReadErr(err)
+		}
+
+		count, err := checkSegmentFrames(name, e)
+		if err != nil {
+			return err
+		}
+		// NOTE - 빈 세그먼트는 마지막 활성 세그먼트뿐이다. 중간에 있으면 다음 세그먼트와 이름이 겹친다.
+		if next = e.BaseOffset + count; next == e.BaseOffset && i < len(m)-1 {
+			return fmt.Errorf("%w: segment %d is empty but isn't the last", ErrInvalidSnapshot, e.BaseOffset)
+		}
+	}
+	return nil
+}
+
+// checkSegmentFrames walks the index of the segment files at name and checks
+// that each entry points at the next length-prefixed record of the store and
+// that the records fill the store, reading only the lengths. It returns how
+// many records the segment holds.
+func checkSegmentFrames(name string, e manifestEntry) (uint64, error) {
+	if e.IndexSize%entWidth != 0 {
+		return 0, fmt.Errorf("%w: segment %d's index ends with a partial entry", ErrInvalidSnapshot, e.BaseOffset)
+	}
+
+	index, err := os.Open(name + indexExt)
+	if err != nil {
+		return 0, err
+	}
+	defer index.Close()
+	store, err := os.Open(name + storeExt)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+
+	entries := bufio.NewReader(index)
+	entry := make([]byte, entWidth)
+	length := make([]byte, lenWidth)
+	var pos uint64
+	count := e.IndexSize / entWidth
+	for i := range count {
+		if _, err := io.ReadFull(entries, entry); err != nil {
+			return 0, err
+		}
+		off := enc.Uint32(entry[:offWidth])
+		if uint64(off) != i || enc.Uint64(entry[offWidth:]) != pos {
+			return 0, fmt.Errorf("%w: segment %d's index entry %d is for offset %d at position %d, not at %d",
+				ErrInvalidSnapshot, e.BaseOffset, i, off, enc.Uint64(entry[offWidth:]), pos)
+		}
+		if e.StoreSize-pos < lenWidth {
+			return 0, fmt.Errorf("%w: segment %d's record %d starts past its store", ErrInvalidSnapshot, e.BaseOffset, i)
+		}
+		if _, err := store.ReadAt(length, int64(pos)); err != nil {
+			return 0, err
+		}
+		size := enc.Uint64(length)
+		if size > e.StoreSize-pos-lenWidth {
+			return 0, fmt.Errorf("%w: segment %d's record %d runs past its store", ErrInvalidSnapshot, e.BaseOffset, i)
+		}
+		pos += lenWidth + size
+	}
+	if pos != e.StoreSize {
+		return 0, fmt.Errorf("%w: segment %d's store has %d bytes after its last indexed record", ErrInvalidSnapshot, e.BaseOffset, e.StoreSize-pos)
+	}
+	return count, nil
+}
+
+// snapshotReadErr reports a snapshot that ends early as an invalid one.
+func snapshotReadErr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %w", ErrInvalidSnapshot, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+func writeFileN(name string, r io.Reader, n uint64) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(f, r, int64(n)); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+var _ raft.FSMSnapshot = (*snapshot)(nil)
+
+type snapshot struct {
+	views []*segmentView
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if err := s.persist(sink); err != nil {
+		_ = sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) persist(w io.Writer) error {
+	m := make(manifest, len(s.views))
+	for i, v := range s.views {
+		m[i] = manifestEntry{
+			BaseOffset: v.baseOffset,
+			StoreSize:  v.storeSize,
+			IndexSize:  uint64(len(v.index)),
+		}
+	}
+
+	if _, err := m.WriteTo(w); err != nil {
+		return err
+	}
+
+	for _, v := range s.views {
+		if _, err := v.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) Release() {
+	for _, v := range s.views {
+		_ = v.Close()
+	}
+}
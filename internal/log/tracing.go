@@ -0,0 +1,37 @@
+package log
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/zrma/proglog/internal/pb"
+	"github.com/zrma/proglog/internal/tracing"
+)
+
+const tracerName = "github.com/zrma/proglog/internal/log"
+
+// startSpan starts a span in the trace of ctx, or of the record's producer
+// if ctx carries none. Records no producer traced don't start traces of
+// their own, which keeps the raft log's appends out of the traces.
+func startSpan(ctx context.Context, record *pb.Record, name string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = tracing.Extract(ctx, record)
+	}
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	// NOTE - 전역 TracerProvider가 바뀔 수 있으므로 tracer를 매번 가져온다.
+	return otel.Tracer(tracerName).Start(ctx, name)
+}
+
+// endSpan records err, if any, on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,416 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-msgpack/v2/codec"
+	"github.com/hashicorp/raft"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+var (
+	_ raft.Transport   = (*GRPCTransport)(nil)
+	_ raft.WithPreVote = (*GRPCTransport)(nil)
+	_ raft.WithClose   = (*GRPCTransport)(nil)
+)
+
+// GRPCTransport is a raft.Transport that carries Raft RPCs as the Raft gRPC
+// service, so Raft traffic goes through the same grpc.Server, credentials
+// and interceptors as client traffic. Register Server() on the grpc.Server
+// serving localAddr; peers are dialed with the given options.
+type GRPCTransport struct {
+	localAddr raft.ServerAddress
+	dialOpts  []grpc.DialOption
+
+	consumeCh chan raft.RPC
+
+	heartbeatFnLock sync.Mutex
+	heartbeatFn     func(raft.RPC)
+
+	connsLock sync.Mutex
+	conns     map[raft.ServerAddress]*grpc.ClientConn
+
+	shutdownLock sync.Mutex
+	shutdown     bool
+	shutdownCh   chan struct{}
+}
+
+const (
+	rpcTimeout        = 10 * time.Second
+	snapshotChunkSize = 64 * 1024
+
+	// maxAppendEntries is how many entries Raft sends in one AppendEntries,
+	// and raftEntryOverhead what each costs on top of its record.
+	maxAppendEntries  = 64
+	raftEntryOverhead = 1 << 10
+)
+
+const (
+	// MaxRecordBytes is the largest encoded record a server carrying Raft
+	// should accept, gRPC's default limit on a message.
+	MaxRecordBytes = 4 << 20
+	// MaxRaftMessageBytes is the largest Raft RPC a GRPCTransport sends: a
+	// full AppendEntries of records of up to MaxRecordBytes. The grpc.Server
+	// serving the transport must accept messages that large.
+	MaxRaftMessageBytes = maxAppendEntries * (MaxRecordBytes + raftEntryOverhead)
+)
+
+// NewGRPCTransport dials peers with opts, allowing messages of up to
+// MaxRaftMessageBytes.
+func NewGRPCTransport(localAddr string, opts ...grpc.DialOption) *GRPCTransport {
+	return &GRPCTransport{
+		localAddr: raft.ServerAddress(localAddr),
+		dialOpts: append([]grpc.DialOption{
+			grpc.WithDefaultCallOptions(
+				grpc.MaxCallSendMsgSize(MaxRaftMessageBytes),
+				grpc.MaxCallRecvMsgSize(MaxRaftMessageBytes),
+			),
+		}, opts...),
+		consumeCh:  make(chan raft.RPC),
+		conns:      make(map[raft.ServerAddress]*grpc.ClientConn),
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+func (t *GRPCTransport) Consumer() <-chan raft.RPC {
+	return t.consumeCh
+}
+
+func (t *GRPCTransport) LocalAddr() raft.ServerAddress {
+	return t.localAddr
+}
+
+func (t *GRPCTransport) AppendEntriesPipeline(_ raft.ServerID, _ raft.ServerAddress) (raft.AppendPipeline, error) {
+	return nil, raft.ErrPipelineReplicationNotSupported
+}
+
+func (t *GRPCTransport) AppendEntries(
+	_ raft.ServerID,
+	target raft.ServerAddress,
+	args *raft.AppendEntriesRequest,
+	resp *raft.AppendEntriesResponse,
+) error {
+	return t.call(target, pb.RaftClient.AppendEntries, args, resp)
+}
+
+func (t *GRPCTransport) RequestVote(
+	_ raft.ServerID,
+	target raft.ServerAddress,
+	args *raft.RequestVoteRequest,
+	resp *raft.RequestVoteResponse,
+) error {
+	return t.call(target, pb.RaftClient.RequestVote, args, resp)
+}
+
+func (t *GRPCTransport) RequestPreVote(
+	_ raft.ServerID,
+	target raft.ServerAddress,
+	args *raft.RequestPreVoteRequest,
+	resp *raft.RequestPreVoteResponse,
+) error {
+	return t.call(target, pb.RaftClient.RequestPreVote, args, resp)
+}
+
+func (t *GRPCTransport) TimeoutNow(
+	_ raft.ServerID,
+	target raft.ServerAddress,
+	args *raft.TimeoutNowRequest,
+	resp *raft.TimeoutNowResponse,
+) error {
+	return t.call(target, pb.RaftClient.TimeoutNow, args, resp)
+}
+
+func (t *GRPCTransport) InstallSnapshot(
+	_ raft.ServerID,
+	target raft.ServerAddress,
+	args *raft.InstallSnapshotRequest,
+	resp *raft.InstallSnapshotResponse,
+	data io.Reader,
+) error {
+	client, err := t.client(target)
+	if err != nil {
+		return err
+	}
+
+	command, err := encodeRaft(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.InstallSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Send(&pb.RaftSnapshotChunk{Command: command}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, snapshotChunkSize)
+	for {
+		n, err := data.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.RaftSnapshotChunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return decodeRaft(out.GetPayload(), resp)
+}
+
+func (t *GRPCTransport) EncodePeer(_ raft.ServerID, addr raft.ServerAddress) []byte {
+	return []byte(addr)
+}
+
+func (t *GRPCTransport) DecodePeer(buf []byte) raft.ServerAddress {
+	return raft.ServerAddress(buf)
+}
+
+func (t *GRPCTransport) SetHeartbeatHandler(cb func(rpc raft.RPC)) {
+	t.heartbeatFnLock.Lock()
+	defer t.heartbeatFnLock.Unlock()
+
+	t.heartbeatFn = cb
+}
+
+func (t *GRPCTransport) Close() error {
+	t.shutdownLock.Lock()
+	defer t.shutdownLock.Unlock()
+
+	if t.shutdown {
+		return nil
+	}
+	t.shutdown = true
+	close(t.shutdownCh)
+
+	t.connsLock.Lock()
+	defer t.connsLock.Unlock()
+
+	for addr, conn := range t.conns {
+		_ = conn.Close()
+		delete(t.conns, addr)
+	}
+	return nil
+}
+
+type raftMethod func(pb.RaftClient, context.Context, *pb.RaftCommand, ...grpc.CallOption) (*pb.RaftCommand, error)
+
+func (t *GRPCTransport) call(target raft.ServerAddress, method raftMethod, args, resp any) error {
+	client, err := t.client(target)
+	if err != nil {
+		return err
+	}
+
+	payload, err := encodeRaft(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	out, err := method(client, ctx, &pb.RaftCommand{Payload: payload})
+	if err != nil {
+		return err
+	}
+	return decodeRaft(out.GetPayload(), resp)
+}
+
+func (t *GRPCTransport) client(target raft.ServerAddress) (pb.RaftClient, error) {
+	t.connsLock.Lock()
+	defer t.connsLock.Unlock()
+
+	if conn, ok := t.conns[target]; ok {
+		return pb.NewRaftClient(conn), nil
+	}
+
+	conn, err := grpc.NewClient(string(target), t.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	t.conns[target] = conn
+
+	return pb.NewRaftClient(conn), nil
+}
+
+// Server returns the Raft gRPC service that hands incoming commands to Raft.
+func (t *GRPCTransport) Server() pb.RaftServer {
+	return &transportServer{transport: t}
+}
+
+var _ pb.RaftServer = (*transportServer)(nil)
+
+type transportServer struct {
+	pb.UnimplementedRaftServer
+	transport *GRPCTransport
+}
+
+func (s *transportServer) AppendEntries(ctx context.Context, in *pb.RaftCommand) (*pb.RaftCommand, error) {
+	var req raft.AppendEntriesRequest
+	if err := decodeRaft(in.GetPayload(), &req); err != nil {
+		return nil, err
+	}
+	return s.transport.dispatch(ctx, &req, nil, isHeartbeat(&req))
+}
+
+func (s *transportServer) RequestVote(ctx context.Context, in *pb.RaftCommand) (*pb.RaftCommand, error) {
+	var req raft.RequestVoteRequest
+	if err := decodeRaft(in.GetPayload(), &req); err != nil {
+		return nil, err
+	}
+	return s.transport.dispatch(ctx, &req, nil, false)
+}
+
+func (s *transportServer) RequestPreVote(ctx context.Context, in *pb.RaftCommand) (*pb.RaftCommand, error) {
+	var req raft.RequestPreVoteRequest
+	if err := decodeRaft(in.GetPayload(), &req); err != nil {
+		return nil, err
+	}
+	return s.transport.dispatch(ctx, &req, nil, false)
+}
+
+func (s *transportServer) TimeoutNow(ctx context.Context, in *pb.RaftCommand) (*pb.RaftCommand, error) {
+	var req raft.TimeoutNowRequest
+	if err := decodeRaft(in.GetPayload(), &req); err != nil {
+		return nil, err
+	}
+	return s.transport.dispatch(ctx, &req, nil, false)
+}
+
+func (s *transportServer) InstallSnapshot(stream pb.Raft_InstallSnapshotServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	var req raft.InstallSnapshotRequest
+	if err := decodeRaft(first.GetCommand(), &req); err != nil {
+		return err
+	}
+
+	data := &snapshotReader{stream: stream, buf: first.GetData()}
+	out, err := s.transport.dispatch(stream.Context(), &req, io.LimitReader(data, req.Size), false)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(out)
+}
+
+// dispatch hands the command to Raft, or to the heartbeat handler when it is
+// a heartbeat, and encodes Raft's response.
+func (t *GRPCTransport) dispatch(ctx context.Context, command any, data io.Reader, heartbeat bool) (*pb.RaftCommand, error) {
+	respCh := make(chan raft.RPCResponse, 1)
+	rpc := raft.RPC{
+		Command:  command,
+		Reader:   data,
+		RespChan: respCh,
+	}
+
+	handled := false
+	if heartbeat {
+		t.heartbeatFnLock.Lock()
+		fn := t.heartbeatFn
+		t.heartbeatFnLock.Unlock()
+		if fn != nil {
+			fn(rpc)
+			handled = true
+		}
+	}
+
+	if !handled {
+		select {
+		case t.consumeCh <- rpc:
+		case <-t.shutdownCh:
+			return nil, status.Error(codes.Unavailable, raft.ErrTransportShutdown.Error())
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	select {
+	case resp := <-respCh:
+		if resp.Error != nil {
+			return nil, status.Error(codes.Unknown, resp.Error.Error())
+		}
+		payload, err := encodeRaft(resp.Response)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.RaftCommand{Payload: payload}, nil
+	case <-t.shutdownCh:
+		return nil, status.Error(codes.Unavailable, raft.ErrTransportShutdown.Error())
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// isHeartbeat matches the heartbeats raft sends to keep followers from
+// starting elections, the same way raft.NetworkTransport does.
+func isHeartbeat(req *raft.AppendEntriesRequest) bool {
+	leaderAddr := req.RPCHeader.Addr
+	if len(leaderAddr) == 0 {
+		leaderAddr = req.Leader //nolint:staticcheck // kept for older peers
+	}
+	return req.Term != 0 && leaderAddr != nil &&
+		req.PrevLogEntry == 0 && req.PrevLogTerm == 0 &&
+		len(req.Entries) == 0 && req.LeaderCommitIndex == 0
+}
+
+type snapshotReader struct {
+	stream pb.Raft_InstallSnapshotServer
+	buf    []byte
+}
+
+func (r *snapshotReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = chunk.GetData()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func encodeRaft(in any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := codec.NewEncoder(&buf, &codec.MsgpackHandle{
+		BasicHandle: codec.BasicHandle{
+			TimeNotBuiltin: true,
+		},
+	})
+	if err := enc.Encode(in); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeRaft(b []byte, out any) error {
+	dec := codec.NewDecoderBytes(b, &codec.MsgpackHandle{})
+	return dec.Decode(out)
+}
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/require"
+	"github.com/travisjeffery/go-dynaport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestGRPCTransport_DistributedLog(t *testing.T) {
+	var logs []*DistributedLog
+	const nodeCount = 3
+	ports := dynaport.Get(nodeCount)
+
+	for i := 0; i < nodeCount; i++ {
+		transport := newGRPCTransport(t, ports[i])
+
+		cfg := Config{}
+		cfg.Raft.Transport = transport
+		cfg.Raft.LocalID = raft.ServerID(fmt.Sprintf("%d", i))
+		// NOTE - 첫 호출에 gRPC 연결 수립이 포함되므로 StreamLayer 테스트보다 여유를 둔다.
+		cfg.Raft.HeartbeatTimeout = 200 * time.Millisecond
+		cfg.Raft.ElectionTimeout = 200 * time.Millisecond
+		cfg.Raft.LeaderLeaseTimeout = 200 * time.Millisecond
+		cfg.Raft.CommitTimeout = 5 * time.Millisecond
+		cfg.Raft.Bootstrap = i == 0
+
+		l, err := NewDistributedLog(t.TempDir(), cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, l.Close())
+		})
+
+		if i == 0 {
+			require.NoError(t, l.WaitForLeader(3*time.Second))
+		} else {
+			require.NoError(t, logs[0].Join(fmt.Sprintf("%d", i), string(transport.LocalAddr())))
+		}
+		logs = append(logs, l)
+	}
+
+	off, err := logs[0].Append(&pb.Record{Value: []byte("over grpc")})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		for _, l := range logs {
+			record, err := l.Read(off)
+			if err != nil || !bytes.Equal([]byte("over grpc"), record.Value) {
+				return false
+			}
+		}
+		return true
+	}, 3*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, logs[0].TransferLeadership("1"))
+	require.Eventually(t, func() bool {
+		_, isLeader := logs[1].Leader()
+		return isLeader
+	}, 3*time.Second, 10*time.Millisecond, "TimeoutNow도 gRPC로 전달되어야 한다")
+}
+
+func TestGRPCTransport_LargeAppendEntries(t *testing.T) {
+	ports := dynaport.Get(2)
+
+	var logs []*DistributedLog
+	for i := range 2 {
+		transport := newGRPCTransport(t, ports[i])
+
+		cfg := Config{}
+		cfg.Raft.Transport = transport
+		cfg.Raft.LocalID = raft.ServerID(fmt.Sprintf("%d", i))
+		cfg.Raft.HeartbeatTimeout = 200 * time.Millisecond
+		cfg.Raft.ElectionTimeout = 200 * time.Millisecond
+		cfg.Raft.LeaderLeaseTimeout = 200 * time.Millisecond
+		cfg.Raft.CommitTimeout = 5 * time.Millisecond
+		cfg.Raft.Bootstrap = i == 0
+
+		l, err := NewDistributedLog(t.TempDir(), cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, l.Close())
+		})
+		logs = append(logs, l)
+	}
+	require.NoError(t, logs[0].WaitForLeader(3*time.Second))
+
+	// NOTE - 팔로워가 조인하기 전에 써 두어서 gRPC 기본 제한인 4MiB를 넘는 AppendEntries 하나로 따라잡게 한다.
+	value := bytes.Repeat([]byte("x"), 1<<20)
+	const count = 6
+	for range count {
+		_, err := logs[0].Append(&pb.Record{Value: value})
+		require.NoError(t, err)
+	}
+	require.NoError(t, logs[0].Join("1", fmt.Sprintf("127.0.0.1:%d", ports[1])))
+
+	require.Eventually(t, func() bool {
+		record, err := logs[1].Read(count - 1)
+		return err == nil && bytes.Equal(value, record.GetValue())
+	}, 5*time.Second, 10*time.Millisecond, "큰 레코드로 채운 AppendEntries도 복제한다")
+}
+
+func TestGRPCTransport_InstallSnapshot(t *testing.T) {
+	ports := dynaport.Get(2)
+	client := newGRPCTransport(t, ports[0])
+	server := newGRPCTransport(t, ports[1])
+
+	// NOTE - 청크 경계를 넘도록 청크 크기보다 큰 스냅샷을 보낸다.
+	data := bytes.Repeat([]byte("snapshot"), snapshotChunkSize/4+1)
+	go func() {
+		rpc := <-server.Consumer()
+		req := rpc.Command.(*raft.InstallSnapshotRequest)
+		got, err := io.ReadAll(rpc.Reader)
+		rpc.Respond(&raft.InstallSnapshotResponse{
+			Term:    req.Term,
+			Success: err == nil && bytes.Equal(data, got),
+		}, err)
+	}()
+
+	var resp raft.InstallSnapshotResponse
+	err := client.InstallSnapshot(
+		"1",
+		server.LocalAddr(),
+		&raft.InstallSnapshotRequest{Term: 7, Size: int64(len(data))},
+		&resp,
+		bytes.NewReader(data),
+	)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), resp.Term)
+	require.True(t, resp.Success)
+}
+
+func newGRPCTransport(t *testing.T, port int) *GRPCTransport {
+	t.Helper()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	ln, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+
+	transport := NewGRPCTransport(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+
+	svr := grpc.NewServer(grpc.MaxRecvMsgSize(MaxRaftMessageBytes))
+	pb.RegisterRaftServer(svr, transport.Server())
+	go func() {
+		_ = svr.Serve(ln)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, transport.Close())
+		svr.Stop()
+	})
+
+	return transport
+}
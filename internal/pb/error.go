@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
@@ -31,3 +32,60 @@ func (e ErrOffsetOutOfRange) GRPCStatus() *status.Status {
 func (e ErrOffsetOutOfRange) Error() string {
 	return e.GRPCStatus().Err().Error()
 }
+
+const (
+	errorDomain        = "proglog"
+	notLeaderReason    = "NOT_LEADER"
+	leaderAddrMetadata = "leader_addr"
+)
+
+type ErrNotLeader struct {
+	LeaderAddr string
+}
+
+func (e ErrNotLeader) GRPCStatus() *status.Status {
+	msg := "not the leader"
+	if e.LeaderAddr == "" {
+		msg += ", no leader elected"
+	} else {
+		msg += ", leader is " + e.LeaderAddr
+	}
+	st := status.New(codes.FailedPrecondition, msg)
+
+	d := &errdetails.ErrorInfo{
+		Reason: notLeaderReason,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			leaderAddrMetadata: e.LeaderAddr,
+		},
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+
+	return std
+}
+
+func (e ErrNotLeader) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// LeaderAddrFromError extracts the leader address carried by an ErrNotLeader
+// status returned from a follower. ok is false for any other error.
+func LeaderAddrFromError(err error) (addr string, ok bool) {
+	st, found := status.FromError(err)
+	if !found {
+		return "", false
+	}
+
+	for _, d := range st.Details() {
+		info, isInfo := d.(*errdetails.ErrorInfo)
+		if !isInfo || info.GetDomain() != errorDomain || info.GetReason() != notLeaderReason {
+			continue
+		}
+		return info.GetMetadata()[leaderAddrMetadata], true
+	}
+
+	return "", false
+}
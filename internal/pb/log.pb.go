@@ -7,8 +7,11 @@
 package pb
 
 import (
+	_ "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2/options"
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -154,8 +157,12 @@ func (x *ConsumeRequest) GetOffset() uint64 {
 }
 
 type ConsumeResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Record        *Record                `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Record *Record                `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	// high_watermark is the offset right after the newest record the server
+	// had when it served this one, so consumers can tell how far behind they
+	// are.
+	HighWatermark uint64 `protobuf:"varint,2,opt,name=high_watermark,json=highWatermark,proto3" json:"high_watermark,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -197,19 +204,312 @@ func (x *ConsumeResponse) GetRecord() *Record {
 	return nil
 }
 
-type Record struct {
+func (x *ConsumeResponse) GetHighWatermark() uint64 {
+	if x != nil {
+		return x.HighWatermark
+	}
+	return 0
+}
+
+type GetOffsetsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOffsetsRequest) Reset() {
+	*x = GetOffsetsRequest{}
+	mi := &file_log_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOffsetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOffsetsRequest) ProtoMessage() {}
+
+func (x *GetOffsetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOffsetsRequest.ProtoReflect.Descriptor instead.
+func (*GetOffsetsRequest) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{4}
+}
+
+type GetOffsetsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// lowest_offset is the offset of the oldest record the log holds.
+	LowestOffset uint64 `protobuf:"varint,1,opt,name=lowest_offset,json=lowestOffset,proto3" json:"lowest_offset,omitempty"`
+	// high_watermark is the offset the next record appended gets; the log is
+	// empty if it equals lowest_offset.
+	HighWatermark uint64 `protobuf:"varint,2,opt,name=high_watermark,json=highWatermark,proto3" json:"high_watermark,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetOffsetsResponse) Reset() {
+	*x = GetOffsetsResponse{}
+	mi := &file_log_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetOffsetsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetOffsetsResponse) ProtoMessage() {}
+
+func (x *GetOffsetsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetOffsetsResponse.ProtoReflect.Descriptor instead.
+func (*GetOffsetsResponse) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetOffsetsResponse) GetLowestOffset() uint64 {
+	if x != nil {
+		return x.LowestOffset
+	}
+	return 0
+}
+
+func (x *GetOffsetsResponse) GetHighWatermark() uint64 {
+	if x != nil {
+		return x.HighWatermark
+	}
+	return 0
+}
+
+// RecordBatch is a batch of records produced or read at once over HTTP.
+type RecordBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*Record              `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordBatch) Reset() {
+	*x = RecordBatch{}
+	mi := &file_log_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordBatch) ProtoMessage() {}
+
+func (x *RecordBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordBatch.ProtoReflect.Descriptor instead.
+func (*RecordBatch) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RecordBatch) GetRecords() []*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type ProduceBatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// offsets are the offsets of the batch's records, in order.
+	Offsets       []uint64 `protobuf:"varint,1,rep,packed,name=offsets,proto3" json:"offsets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProduceBatchResponse) Reset() {
+	*x = ProduceBatchResponse{}
+	mi := &file_log_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProduceBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProduceBatchResponse) ProtoMessage() {}
+
+func (x *ProduceBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProduceBatchResponse.ProtoReflect.Descriptor instead.
+func (*ProduceBatchResponse) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ProduceBatchResponse) GetOffsets() []uint64 {
+	if x != nil {
+		return x.Offsets
+	}
+	return nil
+}
+
+type ListRecordsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Records []*Record              `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	// next_offset is the offset to list from to continue after records.
+	NextOffset    uint64 `protobuf:"varint,2,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRecordsResponse) Reset() {
+	*x = ListRecordsResponse{}
+	mi := &file_log_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRecordsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRecordsResponse) ProtoMessage() {}
+
+func (x *ListRecordsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRecordsResponse.ProtoReflect.Descriptor instead.
+func (*ListRecordsResponse) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListRecordsResponse) GetRecords() []*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *ListRecordsResponse) GetNextOffset() uint64 {
+	if x != nil {
+		return x.NextOffset
+	}
+	return 0
+}
+
+// ErrorResponse is the body of the HTTP API's error responses.
+type ErrorResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Value         []byte                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
-	Offset        uint64                 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
-	Term          uint64                 `protobuf:"varint,3,opt,name=term,proto3" json:"term,omitempty"`
-	Type          uint32                 `protobuf:"varint,4,opt,name=type,proto3" json:"type,omitempty"`
+	Error         *ErrorResponse_Error   `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorResponse) Reset() {
+	*x = ErrorResponse{}
+	mi := &file_log_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorResponse) ProtoMessage() {}
+
+func (x *ErrorResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorResponse.ProtoReflect.Descriptor instead.
+func (*ErrorResponse) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ErrorResponse) GetError() *ErrorResponse_Error {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+type Record struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Value  []byte                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Offset uint64                 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Term   uint64                 `protobuf:"varint,3,opt,name=term,proto3" json:"term,omitempty"`
+	Type   uint32                 `protobuf:"varint,4,opt,name=type,proto3" json:"type,omitempty"`
+	// origin is the id of the node the record was first written to, set when
+	// a Replicator copies the record from another node.
+	Origin string `protobuf:"bytes,5,opt,name=origin,proto3" json:"origin,omitempty"`
+	// origin_offset is the record's offset in the origin node's log.
+	OriginOffset  uint64            `protobuf:"varint,6,opt,name=origin_offset,json=originOffset,proto3" json:"origin_offset,omitempty"`
+	Key           []byte            `protobuf:"bytes,7,opt,name=key,proto3" json:"key,omitempty"`
+	Headers       map[string][]byte `protobuf:"bytes,8,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Record) Reset() {
 	*x = Record{}
-	mi := &file_log_proto_msgTypes[4]
+	mi := &file_log_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -221,7 +521,7 @@ func (x *Record) String() string {
 func (*Record) ProtoMessage() {}
 
 func (x *Record) ProtoReflect() protoreflect.Message {
-	mi := &file_log_proto_msgTypes[4]
+	mi := &file_log_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -234,7 +534,7 @@ func (x *Record) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Record.ProtoReflect.Descriptor instead.
 func (*Record) Descriptor() ([]byte, []int) {
-	return file_log_proto_rawDescGZIP(), []int{4}
+	return file_log_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Record) GetValue() []byte {
@@ -265,29 +565,174 @@ func (x *Record) GetType() uint32 {
 	return 0
 }
 
+func (x *Record) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *Record) GetOriginOffset() uint64 {
+	if x != nil {
+		return x.OriginOffset
+	}
+	return 0
+}
+
+func (x *Record) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *Record) GetHeaders() map[string][]byte {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+type ErrorResponse_Error struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// code is the HTTP status code.
+	Code int32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	// status names the gRPC code in upper snake case, e.g. NOT_FOUND.
+	Status  string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// details are those of the gRPC status, e.g. the leader's address when
+	// a follower can't forward a write.
+	Details       []*anypb.Any `protobuf:"bytes,4,rep,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorResponse_Error) Reset() {
+	*x = ErrorResponse_Error{}
+	mi := &file_log_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorResponse_Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorResponse_Error) ProtoMessage() {}
+
+func (x *ErrorResponse_Error) ProtoReflect() protoreflect.Message {
+	mi := &file_log_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorResponse_Error.ProtoReflect.Descriptor instead.
+func (*ErrorResponse_Error) Descriptor() ([]byte, []int) {
+	return file_log_proto_rawDescGZIP(), []int{9, 0}
+}
+
+func (x *ErrorResponse_Error) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *ErrorResponse_Error) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ErrorResponse_Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ErrorResponse_Error) GetDetails() []*anypb.Any {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
 var File_log_proto protoreflect.FileDescriptor
 
 const file_log_proto_rawDesc = "" +
 	"\n" +
-	"\tlog.proto\x12\x06log.v1\"8\n" +
+	"\tlog.proto\x12\x06log.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x19google/protobuf/any.proto\x1a.protoc-gen-openapiv2/options/annotations.proto\"8\n" +
 	"\x0eProduceRequest\x12&\n" +
 	"\x06record\x18\x01 \x01(\v2\x0e.log.v1.RecordR\x06record\")\n" +
 	"\x0fProduceResponse\x12\x16\n" +
 	"\x06offset\x18\x01 \x01(\x04R\x06offset\"(\n" +
 	"\x0eConsumeRequest\x12\x16\n" +
-	"\x06offset\x18\x01 \x01(\x04R\x06offset\"9\n" +
+	"\x06offset\x18\x01 \x01(\x04R\x06offset\"`\n" +
 	"\x0fConsumeResponse\x12&\n" +
-	"\x06record\x18\x01 \x01(\v2\x0e.log.v1.RecordR\x06record\"^\n" +
+	"\x06record\x18\x01 \x01(\v2\x0e.log.v1.RecordR\x06record\x12%\n" +
+	"\x0ehigh_watermark\x18\x02 \x01(\x04R\rhighWatermark\"\x13\n" +
+	"\x11GetOffsetsRequest\"`\n" +
+	"\x12GetOffsetsResponse\x12#\n" +
+	"\rlowest_offset\x18\x01 \x01(\x04R\flowestOffset\x12%\n" +
+	"\x0ehigh_watermark\x18\x02 \x01(\x04R\rhighWatermark\"7\n" +
+	"\vRecordBatch\x12(\n" +
+	"\arecords\x18\x01 \x03(\v2\x0e.log.v1.RecordR\arecords\"0\n" +
+	"\x14ProduceBatchResponse\x12\x18\n" +
+	"\aoffsets\x18\x01 \x03(\x04R\aoffsets\"`\n" +
+	"\x13ListRecordsResponse\x12(\n" +
+	"\arecords\x18\x01 \x03(\v2\x0e.log.v1.RecordR\arecords\x12\x1f\n" +
+	"\vnext_offset\x18\x02 \x01(\x04R\n" +
+	"nextOffset\"\xc1\x01\n" +
+	"\rErrorResponse\x121\n" +
+	"\x05error\x18\x01 \x01(\v2\x1b.log.v1.ErrorResponse.ErrorR\x05error\x1a}\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12.\n" +
+	"\adetails\x18\x04 \x03(\v2\x14.google.protobuf.AnyR\adetails\"\xa0\x02\n" +
 	"\x06Record\x12\x14\n" +
 	"\x05value\x18\x01 \x01(\fR\x05value\x12\x16\n" +
 	"\x06offset\x18\x02 \x01(\x04R\x06offset\x12\x12\n" +
 	"\x04term\x18\x03 \x01(\x04R\x04term\x12\x12\n" +
-	"\x04type\x18\x04 \x01(\rR\x04type2\x87\x02\n" +
-	"\x03Log\x12:\n" +
-	"\aProduce\x12\x16.log.v1.ProduceRequest\x1a\x17.log.v1.ProduceResponse\x12:\n" +
-	"\aConsume\x12\x16.log.v1.ConsumeRequest\x1a\x17.log.v1.ConsumeResponse\x12D\n" +
-	"\rProduceStream\x12\x16.log.v1.ProduceRequest\x1a\x17.log.v1.ProduceResponse(\x010\x01\x12B\n" +
-	"\rConsumeStream\x12\x16.log.v1.ConsumeRequest\x1a\x17.log.v1.ConsumeResponse0\x01B%Z#github.com/zrma/proglog/internal/pbb\x06proto3"
+	"\x04type\x18\x04 \x01(\rR\x04type\x12\x16\n" +
+	"\x06origin\x18\x05 \x01(\tR\x06origin\x12#\n" +
+	"\rorigin_offset\x18\x06 \x01(\x04R\foriginOffset\x12\x10\n" +
+	"\x03key\x18\a \x01(\fR\x03key\x125\n" +
+	"\aheaders\x18\b \x03(\v2\x1b.log.v1.Record.HeadersEntryR\aheaders\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value:\x028\x012\xaa\a\n" +
+	"\x03Log\x12\xe0\x01\n" +
+	"\aProduce\x12\x16.log.v1.ProduceRequest\x1a\x17.log.v1.ProduceResponse\"\xa3\x01\x92A\x84\x012\x10application/json2\x16application/x-protobuf2\x18application/octet-streamJ>\n" +
+	"\x03201\x127\n" +
+	"\x18The record was appended.\x12\x1b\n" +
+	"\x19\x1a\x17.log.v1.ProduceResponse\x82\xd3\xe4\x93\x02\x15:\x06record\"\v/v1/records\x12\xfd\x01\n" +
+	"\aConsume\x12\x16.log.v1.ConsumeRequest\x1a\x17.log.v1.ConsumeResponse\"\xc0\x01\x92A\x98\x01:\x10application/json:\x16application/x-protobuf:\x18application/octet-streamJR\n" +
+	"\x03200\x12K\n" +
+	"5The record, or its value as application/octet-stream.\x12\x12\n" +
+	"\x10\x1a\x0e.log.v1.Record\x82\xd3\xe4\x93\x02\x1eb\x06record\x12\x14/v1/records/{offset}\x12\x9b\x01\n" +
+	"\n" +
+	"GetOffsets\x12\x19.log.v1.GetOffsetsRequest\x1a\x1a.log.v1.GetOffsetsResponse\"V\x92A@J>\n" +
+	"\x03200\x127\n" +
+	"\x15The range of offsets.\x12\x1e\n" +
+	"\x1c\x1a\x1a.log.v1.GetOffsetsResponse\x82\xd3\xe4\x93\x02\r\x12\v/v1/offsets\x12D\n" +
+	"\rProduceStream\x12\x16.log.v1.ProduceRequest\x1a\x17.log.v1.ProduceResponse(\x010\x01\x12\xdb\x01\n" +
+	"\rConsumeStream\x12\x16.log.v1.ConsumeRequest\x1a\x17.log.v1.ConsumeResponse\"\x96\x01\x92Ay:\x10application/jsonJe\n" +
+	"\x03200\x12^\n" +
+	"?A {\"result\": ConsumeResponse} per line as records are appended.\x12\x1b\n" +
+	"\x19\x1a\x17.log.v1.ConsumeResponse\x82\xd3\xe4\x93\x02\x14\x12\x12/v1/records:stream0\x01B\xbf\x01\x92A\x96\x01\x12\r\n" +
+	"\aproglog2\x02v12\x10application/json2\x16application/x-protobuf:\x10application/json:\x16application/x-protobufR1\n" +
+	"\adefault\x12&\n" +
+	"\tAn error.\x12\x19\n" +
+	"\x17\x1a\x15.log.v1.ErrorResponseZ#github.com/zrma/proglog/internal/pbb\x06proto3"
 
 var (
 	file_log_proto_rawDescOnce sync.Once
@@ -301,30 +746,46 @@ func file_log_proto_rawDescGZIP() []byte {
 	return file_log_proto_rawDescData
 }
 
-var file_log_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_log_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_log_proto_goTypes = []any{
-	(*ProduceRequest)(nil),  // 0: log.v1.ProduceRequest
-	(*ProduceResponse)(nil), // 1: log.v1.ProduceResponse
-	(*ConsumeRequest)(nil),  // 2: log.v1.ConsumeRequest
-	(*ConsumeResponse)(nil), // 3: log.v1.ConsumeResponse
-	(*Record)(nil),          // 4: log.v1.Record
+	(*ProduceRequest)(nil),       // 0: log.v1.ProduceRequest
+	(*ProduceResponse)(nil),      // 1: log.v1.ProduceResponse
+	(*ConsumeRequest)(nil),       // 2: log.v1.ConsumeRequest
+	(*ConsumeResponse)(nil),      // 3: log.v1.ConsumeResponse
+	(*GetOffsetsRequest)(nil),    // 4: log.v1.GetOffsetsRequest
+	(*GetOffsetsResponse)(nil),   // 5: log.v1.GetOffsetsResponse
+	(*RecordBatch)(nil),          // 6: log.v1.RecordBatch
+	(*ProduceBatchResponse)(nil), // 7: log.v1.ProduceBatchResponse
+	(*ListRecordsResponse)(nil),  // 8: log.v1.ListRecordsResponse
+	(*ErrorResponse)(nil),        // 9: log.v1.ErrorResponse
+	(*Record)(nil),               // 10: log.v1.Record
+	(*ErrorResponse_Error)(nil),  // 11: log.v1.ErrorResponse.Error
+	nil,                          // 12: log.v1.Record.HeadersEntry
+	(*anypb.Any)(nil),            // 13: google.protobuf.Any
 }
 var file_log_proto_depIdxs = []int32{
-	4, // 0: log.v1.ProduceRequest.record:type_name -> log.v1.Record
-	4, // 1: log.v1.ConsumeResponse.record:type_name -> log.v1.Record
-	0, // 2: log.v1.Log.Produce:input_type -> log.v1.ProduceRequest
-	2, // 3: log.v1.Log.Consume:input_type -> log.v1.ConsumeRequest
-	0, // 4: log.v1.Log.ProduceStream:input_type -> log.v1.ProduceRequest
-	2, // 5: log.v1.Log.ConsumeStream:input_type -> log.v1.ConsumeRequest
-	1, // 6: log.v1.Log.Produce:output_type -> log.v1.ProduceResponse
-	3, // 7: log.v1.Log.Consume:output_type -> log.v1.ConsumeResponse
-	1, // 8: log.v1.Log.ProduceStream:output_type -> log.v1.ProduceResponse
-	3, // 9: log.v1.Log.ConsumeStream:output_type -> log.v1.ConsumeResponse
-	6, // [6:10] is the sub-list for method output_type
-	2, // [2:6] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	10, // 0: log.v1.ProduceRequest.record:type_name -> log.v1.Record
+	10, // 1: log.v1.ConsumeResponse.record:type_name -> log.v1.Record
+	10, // 2: log.v1.RecordBatch.records:type_name -> log.v1.Record
+	10, // 3: log.v1.ListRecordsResponse.records:type_name -> log.v1.Record
+	11, // 4: log.v1.ErrorResponse.error:type_name -> log.v1.ErrorResponse.Error
+	12, // 5: log.v1.Record.headers:type_name -> log.v1.Record.HeadersEntry
+	13, // 6: log.v1.ErrorResponse.Error.details:type_name -> google.protobuf.Any
+	0,  // 7: log.v1.Log.Produce:input_type -> log.v1.ProduceRequest
+	2,  // 8: log.v1.Log.Consume:input_type -> log.v1.ConsumeRequest
+	4,  // 9: log.v1.Log.GetOffsets:input_type -> log.v1.GetOffsetsRequest
+	0,  // 10: log.v1.Log.ProduceStream:input_type -> log.v1.ProduceRequest
+	2,  // 11: log.v1.Log.ConsumeStream:input_type -> log.v1.ConsumeRequest
+	1,  // 12: log.v1.Log.Produce:output_type -> log.v1.ProduceResponse
+	3,  // 13: log.v1.Log.Consume:output_type -> log.v1.ConsumeResponse
+	5,  // 14: log.v1.Log.GetOffsets:output_type -> log.v1.GetOffsetsResponse
+	1,  // 15: log.v1.Log.ProduceStream:output_type -> log.v1.ProduceResponse
+	3,  // 16: log.v1.Log.ConsumeStream:output_type -> log.v1.ConsumeResponse
+	12, // [12:17] is the sub-list for method output_type
+	7,  // [7:12] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_log_proto_init() }
@@ -338,7 +799,7 @@ func file_log_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_log_proto_rawDesc), len(file_log_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -0,0 +1,11 @@
+package pb
+
+import (
+	_ "embed"
+)
+
+// OpenAPI describes the HTTP routes annotated in log.proto, generated along
+// with the gateway.
+//
+//go:embed proglog.swagger.json
+var OpenAPI []byte
@@ -0,0 +1,200 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: raft.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// RaftCommand is a raft request or response encoded with msgpack, the same
+// encoding raft's network transport uses.
+type RaftCommand struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payload       []byte                 `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RaftCommand) Reset() {
+	*x = RaftCommand{}
+	mi := &file_raft_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RaftCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RaftCommand) ProtoMessage() {}
+
+func (x *RaftCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_raft_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RaftCommand.ProtoReflect.Descriptor instead.
+func (*RaftCommand) Descriptor() ([]byte, []int) {
+	return file_raft_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RaftCommand) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+// RaftSnapshotChunk streams a snapshot; the first chunk carries the encoded
+// InstallSnapshotRequest and the following ones the snapshot data.
+type RaftSnapshotChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       []byte                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RaftSnapshotChunk) Reset() {
+	*x = RaftSnapshotChunk{}
+	mi := &file_raft_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RaftSnapshotChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RaftSnapshotChunk) ProtoMessage() {}
+
+func (x *RaftSnapshotChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_raft_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RaftSnapshotChunk.ProtoReflect.Descriptor instead.
+func (*RaftSnapshotChunk) Descriptor() ([]byte, []int) {
+	return file_raft_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RaftSnapshotChunk) GetCommand() []byte {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *RaftSnapshotChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_raft_proto protoreflect.FileDescriptor
+
+const file_raft_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"raft.proto\x12\x06log.v1\"'\n" +
+	"\vRaftCommand\x12\x18\n" +
+	"\apayload\x18\x01 \x01(\fR\apayload\"A\n" +
+	"\x11RaftSnapshotChunk\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\fR\acommand\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data2\xb3\x02\n" +
+	"\x04Raft\x129\n" +
+	"\rAppendEntries\x12\x13.log.v1.RaftCommand\x1a\x13.log.v1.RaftCommand\x127\n" +
+	"\vRequestVote\x12\x13.log.v1.RaftCommand\x1a\x13.log.v1.RaftCommand\x12:\n" +
+	"\x0eRequestPreVote\x12\x13.log.v1.RaftCommand\x1a\x13.log.v1.RaftCommand\x126\n" +
+	"\n" +
+	"TimeoutNow\x12\x13.log.v1.RaftCommand\x1a\x13.log.v1.RaftCommand\x12C\n" +
+	"\x0fInstallSnapshot\x12\x19.log.v1.RaftSnapshotChunk\x1a\x13.log.v1.RaftCommand(\x01B%Z#github.com/zrma/proglog/internal/pbb\x06proto3"
+
+var (
+	file_raft_proto_rawDescOnce sync.Once
+	file_raft_proto_rawDescData []byte
+)
+
+func file_raft_proto_rawDescGZIP() []byte {
+	file_raft_proto_rawDescOnce.Do(func() {
+		file_raft_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_raft_proto_rawDesc), len(file_raft_proto_rawDesc)))
+	})
+	return file_raft_proto_rawDescData
+}
+
+var file_raft_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_raft_proto_goTypes = []any{
+	(*RaftCommand)(nil),       // 0: log.v1.RaftCommand
+	(*RaftSnapshotChunk)(nil), // 1: log.v1.RaftSnapshotChunk
+}
+var file_raft_proto_depIdxs = []int32{
+	0, // 0: log.v1.Raft.AppendEntries:input_type -> log.v1.RaftCommand
+	0, // 1: log.v1.Raft.RequestVote:input_type -> log.v1.RaftCommand
+	0, // 2: log.v1.Raft.RequestPreVote:input_type -> log.v1.RaftCommand
+	0, // 3: log.v1.Raft.TimeoutNow:input_type -> log.v1.RaftCommand
+	1, // 4: log.v1.Raft.InstallSnapshot:input_type -> log.v1.RaftSnapshotChunk
+	0, // 5: log.v1.Raft.AppendEntries:output_type -> log.v1.RaftCommand
+	0, // 6: log.v1.Raft.RequestVote:output_type -> log.v1.RaftCommand
+	0, // 7: log.v1.Raft.RequestPreVote:output_type -> log.v1.RaftCommand
+	0, // 8: log.v1.Raft.TimeoutNow:output_type -> log.v1.RaftCommand
+	0, // 9: log.v1.Raft.InstallSnapshot:output_type -> log.v1.RaftCommand
+	5, // [5:10] is the sub-list for method output_type
+	0, // [0:5] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_raft_proto_init() }
+func file_raft_proto_init() {
+	if File_raft_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_raft_proto_rawDesc), len(file_raft_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_raft_proto_goTypes,
+		DependencyIndexes: file_raft_proto_depIdxs,
+		MessageInfos:      file_raft_proto_msgTypes,
+	}.Build()
+	File_raft_proto = out.File
+	file_raft_proto_goTypes = nil
+	file_raft_proto_depIdxs = nil
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/zrma/proglog/internal/pb"
+	"github.com/zrma/proglog/internal/tracing"
+)
+
+// Membership changes the set of servers replicating the log and which of
+// them leads.
+type Membership interface {
+	Join(id, addr string) error
+	JoinNonvoter(id, addr string) error
+	Leave(id string) error
+	GetServers() ([]*pb.Server, error)
+	TransferLeadership(id string) error
+}
+
+// ReplicationLagReporter reports how far each replica is behind.
+type ReplicationLagReporter interface {
+	ReplicationLag() []*pb.ReplicationLag
+}
+
+const adminAction = "admin"
+
+var _ pb.AdminServer = (*adminServer)(nil)
+
+type adminServer struct {
+	pb.UnimplementedAdminServer
+	*grpcServer
+}
+
+func (s adminServer) Join(ctx context.Context, req *pb.JoinRequest) (*pb.JoinResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if addr, ok := s.leader(); !ok {
+		addr, err := s.forwardTo(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return s.forwarder.Join(ctx, addr, req)
+	}
+
+	membership, err := s.membership()
+	if err != nil {
+		return nil, err
+	}
+	join := membership.Join
+	if req.GetNonvoter() {
+		join = membership.JoinNonvoter
+	}
+	if err := join(req.GetId(), req.GetRpcAddr()); err != nil {
+		return nil, err
+	}
+
+	return &pb.JoinResponse{}, nil
+}
+
+func (s adminServer) Leave(ctx context.Context, req *pb.LeaveRequest) (*pb.LeaveResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if addr, ok := s.leader(); !ok {
+		addr, err := s.forwardTo(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return s.forwarder.Leave(ctx, addr, req)
+	}
+
+	membership, err := s.membership()
+	if err != nil {
+		return nil, err
+	}
+	if err := membership.Leave(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	return &pb.LeaveResponse{}, nil
+}
+
+func (s adminServer) GetServers(ctx context.Context, _ *pb.GetServersRequest) (*pb.GetServersResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	membership, err := s.membership()
+	if err != nil {
+		return nil, err
+	}
+	servers, err := membership.GetServers()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetServersResponse{Servers: servers}, nil
+}
+
+func (s adminServer) TransferLeadership(ctx context.Context, req *pb.TransferLeadershipRequest) (*pb.TransferLeadershipResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if addr, ok := s.leader(); !ok {
+		addr, err := s.forwardTo(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return s.forwarder.TransferLeadership(ctx, addr, req)
+	}
+
+	membership, err := s.membership()
+	if err != nil {
+		return nil, err
+	}
+	if err := membership.TransferLeadership(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	return &pb.TransferLeadershipResponse{}, nil
+}
+
+// membership returns the Membership the Raft RPCs change, which servers
+// replicating without Raft don't have.
+func (s adminServer) membership() (Membership, error) {
+	if s.Membership == nil {
+		return nil, status.Error(codes.Unimplemented, "server doesn't replicate with Raft")
+	}
+	return s.Membership, nil
+}
+
+// GetReplicationLag reports the lag as seen by this server; only the Raft
+// leader knows its followers' lag.
+func (s adminServer) GetReplicationLag(ctx context.Context, _ *pb.GetReplicationLagRequest) (*pb.GetReplicationLagResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	res := &pb.GetReplicationLagResponse{}
+	if s.ReplicationLag != nil {
+		res.Lags = s.ReplicationLag.ReplicationLag()
+	}
+	return res, nil
+}
+
+func (s adminServer) GetTelemetry(ctx context.Context, _ *pb.GetTelemetryRequest) (*pb.GetTelemetryResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	return &pb.GetTelemetryResponse{Telemetry: s.telemetry()}, nil
+}
+
+func (s adminServer) SetTelemetry(ctx context.Context, req *pb.SetTelemetryRequest) (*pb.SetTelemetryResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if level := req.GetTelemetry().GetLogLevel(); level != "" {
+		if s.LogLevel == nil {
+			return nil, status.Error(codes.FailedPrecondition, "log level isn't configurable")
+		}
+		l, err := zapcore.ParseLevel(level)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		s.LogLevel.SetLevel(l)
+	}
+
+	if sampling := req.GetTelemetry().GetSampling(); sampling != nil {
+		if s.Sampler == nil {
+			return nil, status.Error(codes.FailedPrecondition, "trace sampling isn't configurable")
+		}
+		if err := s.Sampler.SetConfig(tracing.SamplingConfig{
+			Ratio:        sampling.GetRatio(),
+			MethodRatios: sampling.GetMethodRatios(),
+			SampleErrors: sampling.GetSampleErrors(),
+		}); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	return &pb.SetTelemetryResponse{Telemetry: s.telemetry()}, nil
+}
+
+// telemetry reports the configurable parts of the telemetry; the others are
+// left unset.
+func (s adminServer) telemetry() *pb.Telemetry {
+	t := &pb.Telemetry{}
+	if s.LogLevel != nil {
+		t.LogLevel = s.LogLevel.Level().String()
+	}
+	if s.Sampler != nil {
+		config := s.Sampler.Config()
+		t.Sampling = &pb.TraceSampling{
+			Ratio:        config.Ratio,
+			MethodRatios: config.MethodRatios,
+			SampleErrors: config.SampleErrors,
+		}
+	}
+	return t
+}
+
+func (s adminServer) authorizeAdmin(ctx context.Context) error {
+	return s.Authorizer.Authorize(
+		subject(ctx),
+		objectWildcard,
+		adminAction,
+	)
+}
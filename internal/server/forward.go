@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+// LeaderLocator reports whether this node may accept writes and, if not,
+// where the current leader's RPC endpoint is.
+type LeaderLocator interface {
+	Leader() (addr string, isLeader bool)
+}
+
+// forwardedMetadata marks a request a follower forwarded, so the server it
+// reached doesn't forward it again if it isn't the leader either.
+const forwardedMetadata = "proglog-forwarded"
+
+// forwardTo returns the leader's address to forward a write to, for a server
+// that isn't the leader. A request that was already forwarded fails with
+// Unavailable instead: during an election the node it reached may lead no
+// longer and forwarding again could bounce it between followers.
+func (s grpcServer) forwardTo(ctx context.Context, addr string) (string, error) {
+	if s.DisableForwarding || addr == "" {
+		return "", pb.ErrNotLeader{LeaderAddr: addr}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(forwardedMetadata)) > 0 {
+		return "", status.Errorf(codes.Unavailable, "forwarded to a server that isn't the leader, leader is %s", addr)
+	}
+	return addr, nil
+}
+
+// forwarder proxies write RPCs from a follower to the leader over a single
+// connection that is redialed whenever leadership moves.
+type forwarder struct {
+	opts []grpc.DialOption
+
+	mu   sync.Mutex
+	addr string
+	cc   *grpc.ClientConn
+}
+
+func newForwarder(config *Config) *forwarder {
+	var opts []grpc.DialOption
+	if config.PeerTLSConfig != nil {
+		creds := credentials.NewTLS(config.PeerTLSConfig)
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	opts = append(opts, grpc.WithStatsHandler(otelgrpc.NewClientHandler()))
+	return &forwarder{opts: opts}
+}
+
+func (f *forwarder) Produce(ctx context.Context, addr string, req *pb.ProduceRequest) (*pb.ProduceResponse, error) {
+	conn, err := f.conn(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, forwardedMetadata, "true")
+	return pb.NewLogClient(conn).Produce(ctx, req)
+}
+
+func (f *forwarder) Join(ctx context.Context, addr string, req *pb.JoinRequest) (*pb.JoinResponse, error) {
+	conn, err := f.conn(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, forwardedMetadata, "true")
+	return pb.NewAdminClient(conn).Join(ctx, req)
+}
+
+func (f *forwarder) Leave(ctx context.Context, addr string, req *pb.LeaveRequest) (*pb.LeaveResponse, error) {
+	conn, err := f.conn(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, forwardedMetadata, "true")
+	return pb.NewAdminClient(conn).Leave(ctx, req)
+}
+
+func (f *forwarder) TransferLeadership(ctx context.Context, addr string, req *pb.TransferLeadershipRequest) (*pb.TransferLeadershipResponse, error) {
+	conn, err := f.conn(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, forwardedMetadata, "true")
+	return pb.NewAdminClient(conn).TransferLeadership(ctx, req)
+}
+
+// Close closes the connection to the leader; a later write dials it again.
+func (f *forwarder) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cc == nil {
+		return nil
+	}
+	err := f.cc.Close()
+	f.addr, f.cc = "", nil
+	return err
+}
+
+func (f *forwarder) conn(addr string) (*grpc.ClientConn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cc != nil && f.addr == addr {
+		return f.cc, nil
+	}
+
+	// NOTE - 리더가 바뀌면 이전 리더로 가는 연결을 닫는다.
+	if f.cc != nil {
+		_ = f.cc.Close()
+		f.cc = nil
+	}
+
+	cc, err := grpc.NewClient(addr, f.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	f.addr = addr
+	f.cc = cc
+	return cc, nil
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+// newGateway transcodes the HTTP routes annotated in log.proto to the
+// server. It calls the server in process rather than over gRPC, so each
+// request is authorized as the subject of its own client certificate.
+func newGateway(svr *grpcServer) (*runtime.ServeMux, error) {
+	jsonPb := &runtime.JSONPb{MarshalOptions: jsonMarshaler}
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, jsonPb),
+		runtime.WithMarshalerOption(jsonContentType, jsonPb),
+		runtime.WithMarshalerOption(protobufContentType, protoMarshaler{&runtime.ProtoMarshaller{}}),
+		runtime.WithMarshalerOption(rawContentType, rawMarshaler{JSONPb: jsonPb}),
+		runtime.WithErrorHandler(func(_ context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, _ *http.Request, err error) {
+			writeError(w, httpStatus(err), status.Convert(err))
+		}),
+		runtime.WithForwardResponseOption(forwardResponse),
+	)
+	if err := pb.RegisterLogHandlerClient(context.Background(), mux, localClient{svr: svr}); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// forwardResponse answers a produce with 201 Created and the record's
+// location, and tells the offset of a record read, in case its value is sent
+// raw.
+func forwardResponse(_ context.Context, w http.ResponseWriter, m proto.Message) error {
+	switch m := m.(type) {
+	case *pb.ProduceResponse:
+		w.Header().Set("Location", "/v1/records/"+strconv.FormatUint(m.GetOffset(), 10))
+		w.WriteHeader(http.StatusCreated)
+	case consumeResponse:
+		w.Header().Set(offsetHeader, strconv.FormatUint(m.GetRecord().GetOffset(), 10))
+	}
+	return nil
+}
+
+// consumeResponse matches a ConsumeResponse, also when the gateway wraps it
+// to send just its record.
+type consumeResponse interface {
+	proto.Message
+	GetRecord() *pb.Record
+}
+
+var _ runtime.Marshaler = protoMarshaler{}
+
+// protoMarshaler is runtime.ProtoMarshaller labeled as protobuf. It also
+// decodes the request body into the field of a message, e.g. the record of
+// a ProduceRequest.
+type protoMarshaler struct {
+	*runtime.ProtoMarshaller
+}
+
+func (m protoMarshaler) ContentType(any) string {
+	return protobufContentType
+}
+
+func (m protoMarshaler) Unmarshal(data []byte, v any) error {
+	return m.ProtoMarshaller.Unmarshal(data, messageOf(v))
+}
+
+func (m protoMarshaler) NewDecoder(r io.Reader) runtime.Decoder {
+	return runtime.DecoderFunc(func(v any) error {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return m.Unmarshal(b, v)
+	})
+}
+
+var _ runtime.Marshaler = rawMarshaler{}
+
+// rawMarshaler reads and writes a record as its bare value. Everything else,
+// like the offset a produce returns, is JSON.
+type rawMarshaler struct {
+	*runtime.JSONPb
+}
+
+func (m rawMarshaler) ContentType(v any) string {
+	switch v.(type) {
+	case *pb.Record, consumeResponse:
+		return rawContentType
+	default:
+		return jsonContentType
+	}
+}
+
+func (m rawMarshaler) Marshal(v any) ([]byte, error) {
+	if record, ok := v.(*pb.Record); ok {
+		return record.GetValue(), nil
+	}
+	return m.JSONPb.Marshal(v)
+}
+
+func (m rawMarshaler) NewDecoder(r io.Reader) runtime.Decoder {
+	return runtime.DecoderFunc(func(v any) error {
+		record, ok := messageOf(v).(*pb.Record)
+		if !ok {
+			return m.JSONPb.NewDecoder(r).Decode(v)
+		}
+		value, err := io.ReadAll(r)
+		record.Value = value
+		return err
+	})
+}
+
+// messageOf returns the message a pointer to a message field points to,
+// allocating it if it's nil, and v itself otherwise.
+func messageOf(v any) any {
+	rv := reflect.ValueOf(v)
+	if _, ok := v.(proto.Message); ok || rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Pointer {
+		return v
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+	}
+	return rv.Elem().Interface()
+}
+
+var _ pb.LogClient = localClient{}
+
+// localClient calls a grpcServer in process with the context of the HTTP
+// request, which carries the client's subject, see httpContext.
+type localClient struct {
+	svr *grpcServer
+}
+
+func (c localClient) Produce(ctx context.Context, in *pb.ProduceRequest, _ ...grpc.CallOption) (*pb.ProduceResponse, error) {
+	return c.svr.Produce(ctx, in)
+}
+
+func (c localClient) Consume(ctx context.Context, in *pb.ConsumeRequest, _ ...grpc.CallOption) (*pb.ConsumeResponse, error) {
+	return c.svr.Consume(ctx, in)
+}
+
+func (c localClient) GetOffsets(ctx context.Context, in *pb.GetOffsetsRequest, _ ...grpc.CallOption) (*pb.GetOffsetsResponse, error) {
+	return c.svr.GetOffsets(ctx, in)
+}
+
+func (c localClient) ProduceStream(context.Context, ...grpc.CallOption) (grpc.BidiStreamingClient[pb.ProduceRequest, pb.ProduceResponse], error) {
+	return nil, status.Error(codes.Unimplemented, "ProduceStream isn't served over HTTP")
+}
+
+func (c localClient) ConsumeStream(ctx context.Context, in *pb.ConsumeRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[pb.ConsumeResponse], error) {
+	stream := &localStream{
+		ctx:  ctx,
+		ch:   make(chan *pb.ConsumeResponse),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(stream.done)
+		stream.err = c.svr.ConsumeStream(in, localServerStream{stream})
+	}()
+	return stream, nil
+}
+
+var _ grpc.ServerStreamingClient[pb.ConsumeResponse] = (*localStream)(nil)
+
+// localStream hands the responses of a ConsumeStream served in process to
+// its client. The stream ends when the client's context is done.
+type localStream struct {
+	ctx  context.Context
+	ch   chan *pb.ConsumeResponse
+	done chan struct{}
+	err  error
+}
+
+func (s *localStream) Recv() (*pb.ConsumeResponse, error) {
+	select {
+	case res := <-s.ch:
+		return res, nil
+	case <-s.done:
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+}
+
+func (s *localStream) Header() (metadata.MD, error) { return nil, nil }
+func (s *localStream) Trailer() metadata.MD         { return nil }
+func (s *localStream) CloseSend() error             { return nil }
+func (s *localStream) Context() context.Context     { return s.ctx }
+
+func (s *localStream) SendMsg(any) error {
+	return errors.New("can't send on a server stream")
+}
+
+func (s *localStream) RecvMsg(m any) error {
+	res, err := s.Recv()
+	if err != nil {
+		return err
+	}
+	proto.Merge(m.(proto.Message), res)
+	return nil
+}
+
+var _ pb.Log_ConsumeStreamServer = localServerStream{}
+
+// localServerStream is the server's end of a localStream.
+type localServerStream struct {
+	*localStream
+}
+
+func (s localServerStream) Send(res *pb.ConsumeResponse) error {
+	select {
+	case s.ch <- res:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s localServerStream) SetHeader(metadata.MD) error  { return nil }
+func (s localServerStream) SendHeader(metadata.MD) error { return nil }
+func (s localServerStream) SetTrailer(metadata.MD)       {}
+
+func (s localServerStream) SendMsg(m any) error {
+	return s.Send(m.(*pb.ConsumeResponse))
+}
+
+func (s localServerStream) RecvMsg(any) error {
+	return errors.New("can't receive on a server stream")
+}
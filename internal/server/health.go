@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Readiness tells whether the server can serve, e.g. a log.DistributedLog
+// that knows its leader and has applied what it committed.
+type Readiness interface {
+	Ready() error
+}
+
+// healthPollInterval is how often Watch checks for a change in readiness.
+var healthPollInterval = time.Second
+
+var _ healthpb.HealthServer = (*healthServer)(nil)
+
+// healthServer serves the standard grpc.health.v1 service, so load balancers
+// can tell whether a server is ready. It reports the same status for the
+// server as a whole, the empty service name, and each of its services. It
+// doesn't authorize, so it answers any client with a valid certificate.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	readiness Readiness
+	services  []string
+}
+
+func newHealthServer(readiness Readiness, services []string) *healthServer {
+	return &healthServer{
+		readiness: readiness,
+		services:  append([]string{""}, services...),
+	}
+}
+
+func (s *healthServer) Check(_ context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !slices.Contains(s.services, req.GetService()) {
+		return nil, status.Errorf(codes.NotFound, "unknown service: %q", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: s.status()}, nil
+}
+
+func (s *healthServer) List(context.Context, *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	res := &healthpb.HealthListResponse{Statuses: make(map[string]*healthpb.HealthCheckResponse, len(s.services))}
+	for _, service := range s.services {
+		res.Statuses[service] = &healthpb.HealthCheckResponse{Status: s.status()}
+	}
+	return res, nil
+}
+
+func (s *healthServer) Watch(req *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	if !slices.Contains(s.services, req.GetService()) {
+		// NOTE - 명세에 따라 모르는 서비스도 스트림을 끊지 않고 SERVICE_UNKNOWN을 알린다.
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN}); err != nil {
+			return err
+		}
+		<-stream.Context().Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		if current := s.status(); current != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+				return err
+			}
+			last = current
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// status is SERVING if the server is ready, or has no Readiness to ask, and
+// NOT_SERVING otherwise.
+func (s *healthServer) status() healthpb.HealthCheckResponse_ServingStatus {
+	if s.readiness != nil && s.readiness.Ready() != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
@@ -1,95 +1,408 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/zrma/proglog/internal/pb"
 )
 
-func NewHTTPServer(addr string) *http.Server {
-	httpSrv := newHttpServer()
+const (
+	jsonContentType     = "application/json"
+	protobufContentType = "application/x-protobuf"
+	rawContentType      = "application/octet-stream"
+
+	// offsetHeader carries the offset of a record served as raw bytes.
+	offsetHeader = "Proglog-Offset"
+
+	defaultListLimit = 100
+	maxListLimit     = 1000
+	maxBodyBytes     = 4 << 20
+)
+
+// NewHTTPServer serves the log of config over HTTP with the same
+// authorization, leader forwarding and errors as the gRPC server. Clients are
+// identified by the common name of their TLS client certificate, so set the
+// server's TLSConfig to require one.
+//
+//	POST /v1/records               appends a record or a RecordBatch
+//	GET  /v1/records/{offset}      reads a record
+//	GET  /v1/records:stream        streams records as newline-delimited JSON
+//	GET  /v1/records?from=&limit=  lists up to limit records from offset from
+//	GET  /v1/records/events?from=  tails the log as Server-Sent Events
+//	GET  /v1/records/ws?from=      tails the log over a WebSocket
+//	GET  /v1/openapi.json          describes the routes of log.proto
+//
+// The first three are transcoded from the Log service by a gateway generated
+// from the HTTP annotations of log.proto; the others, which the service has
+// no RPCs for, are written here.
+//
+// Bodies are protojson (application/json, the default), protobuf
+// (application/x-protobuf) or, for a single record, its raw value
+// (application/octet-stream), picked by Content-Type and Accept. A protobuf
+// batch is sent as application/x-protobuf; proto=log.v1.RecordBatch and a
+// JSON one as an object with a records field. Errors are ErrorResponses in
+// JSON, like {"error": {"code": 404, "status": "NOT_FOUND", "message": "..."}}.
+//
+// The tails start at offset from, or at the end of the log without it, and
+// push records as they're appended, see handleEvents and handleWebSocket.
+func NewHTTPServer(addr string, config *Config) (*http.Server, error) {
+	svr, err := newGrpcServer(config)
+	if err != nil {
+		return nil, err
+	}
+	gateway, err := newGateway(svr)
+	if err != nil {
+		return nil, err
+	}
+	httpSrv := &httpServer{grpcServer: svr, gateway: gateway}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /", httpSrv.handleProduce)
-	mux.HandleFunc("GET /", httpSrv.handleConsume)
+	mux.HandleFunc("POST /v1/records", httpSrv.handleProduce)
+	mux.HandleFunc("GET /v1/records/{offset}", httpSrv.serveGateway(jsonContentType, protobufContentType, rawContentType))
+	mux.HandleFunc("GET /v1/records:stream", httpSrv.serveGateway(jsonContentType))
+	mux.HandleFunc("GET /v1/records", httpSrv.handleList)
+	mux.HandleFunc("GET /v1/offsets", httpSrv.serveGateway(jsonContentType, protobufContentType))
+	mux.HandleFunc("GET /v1/records/events", httpSrv.handleEvents)
+	mux.HandleFunc("GET /v1/records/ws", httpSrv.handleWebSocket)
+	mux.HandleFunc("GET /v1/openapi.json", handleOpenAPI)
 
 	return &http.Server{
 		Addr:    addr,
 		Handler: mux,
-	}
+	}, nil
 }
 
-func newHttpServer() *httpServer {
-	return &httpServer{
-		Log: NewLog(),
+type httpServer struct {
+	*grpcServer
+	gateway http.Handler
+}
+
+// serveGateway passes requests to the gateway with their Accept header
+// resolved to one of offers, since the gateway only matches it verbatim.
+func (s *httpServer) serveGateway(offers ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accept, ok := negotiate(r, offers...)
+		if !ok {
+			writeError(w, http.StatusNotAcceptable, status.Newf(codes.InvalidArgument, "responses are %s", strings.Join(offers, ", ")))
+			return
+		}
+		r.Header.Set("Accept", accept)
+		s.gateway.ServeHTTP(w, r.WithContext(httpContext(r)))
 	}
 }
 
-type httpServer struct {
-	Log *Log
+func handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
+	_, _ = w.Write(pb.OpenAPI)
 }
 
+// handleProduce appends batches itself and passes single records on to the
+// gateway.
 func (s *httpServer) handleProduce(w http.ResponseWriter, r *http.Request) {
-	var req ProduceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	accept, ok := negotiate(r, jsonContentType, protobufContentType)
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, status.New(codes.InvalidArgument, "responses are JSON or protobuf"))
+		return
+	}
+
+	mediaType, params := jsonContentType, map[string]string{}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, params, err = mime.ParseMediaType(ct); err != nil {
+			writeError(w, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()))
+			return
+		}
+	}
+	switch mediaType {
+	case jsonContentType, protobufContentType, rawContentType:
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, status.Newf(codes.InvalidArgument, "unsupported content type: %q", mediaType))
 		return
 	}
 
-	offset, err := s.Log.Append(req.Record)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if errors.As(err, new(*http.MaxBytesError)) {
+		writeError(w, http.StatusRequestEntityTooLarge, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()))
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	resp := ProduceResponse{Offset: offset}
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	records, err := decodeBatch(mediaType, params, body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+	if records == nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		s.serveGateway(jsonContentType, protobufContentType)(w, r)
 		return
 	}
+
+	// NOTE - 배치는 한 레코드씩 쓰므로 중간에 실패하면 앞선 레코드만 남는다.
+	ctx := httpContext(r)
+	offsets := make([]uint64, 0, len(records))
+	for _, record := range records {
+		res, err := s.Produce(ctx, &pb.ProduceRequest{Record: record})
+		if err != nil {
+			writeError(w, httpStatus(err), status.Convert(err))
+			return
+		}
+		offsets = append(offsets, res.GetOffset())
+	}
+	writeMessage(w, accept, http.StatusCreated, &pb.ProduceBatchResponse{Offsets: offsets})
 }
 
-func (s *httpServer) handleConsume(w http.ResponseWriter, r *http.Request) {
-	var req ConsumeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// decodeBatch reads the records of a produce request's body if it's a batch,
+// and returns nil records if it's a single record.
+func decodeBatch(mediaType string, params map[string]string, body []byte) ([]*pb.Record, error) {
+	var batch pb.RecordBatch
+	switch mediaType {
+	case jsonContentType:
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(body, &probe); err != nil {
+			return nil, err
+		}
+		if _, ok := probe["records"]; !ok {
+			return nil, nil
+		}
+		if err := protojson.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+	case protobufContentType:
+		switch params["proto"] {
+		case "", "log.v1.Record":
+			return nil, nil
+		case "log.v1.RecordBatch":
+			if err := proto.Unmarshal(body, &batch); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown message: %q", params["proto"])
+		}
+	default:
+		return nil, nil
+	}
+
+	if len(batch.GetRecords()) == 0 {
+		return nil, errors.New("empty batch")
+	}
+	return batch.GetRecords(), nil
+}
+
+func (s *httpServer) handleList(w http.ResponseWriter, r *http.Request) {
+	accept, ok := negotiate(r, jsonContentType, protobufContentType)
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, status.New(codes.InvalidArgument, "responses are JSON or protobuf"))
 		return
 	}
 
-	record, err := s.Log.Read(req.Offset)
+	from, err := queryUint(r, "from", 0)
 	if err != nil {
-		if errors.Is(err, ErrRecordNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+	limit, err := queryUint(r, "limit", defaultListLimit)
+	if err != nil || limit == 0 || limit > maxListLimit {
+		writeError(w, http.StatusBadRequest, status.Newf(codes.InvalidArgument, "limit must be 1 to %d", maxListLimit))
+		return
+	}
+
+	ctx := httpContext(r)
+	res := &pb.ListRecordsResponse{NextOffset: from}
+	for uint64(len(res.Records)) < limit {
+		consumed, err := s.Consume(ctx, &pb.ConsumeRequest{Offset: res.NextOffset})
+		if errors.As(err, &pb.ErrOffsetOutOfRange{}) {
+			break
+		}
+		if err != nil {
+			writeError(w, httpStatus(err), status.Convert(err))
 			return
 		}
+		res.Records = append(res.Records, consumed.GetRecord())
+		res.NextOffset++
+	}
+	writeMessage(w, accept, http.StatusOK, res)
+}
+
+func queryUint(r *http.Request, name string, def uint64) (uint64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return n, nil
+}
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// negotiate picks the media type of offers the request's Accept header
+// prefers, the first offer if it has none. ok is false if none is
+// acceptable.
+func negotiate(r *http.Request, offers ...string) (mediaType string, ok bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0], true
+	}
+
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []accepted
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, accepted{mediaType: mt, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, rng := range ranges {
+		for _, offer := range offers {
+			if rng.mediaType == offer || rng.mediaType == "*/*" ||
+				(strings.HasSuffix(rng.mediaType, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(rng.mediaType, "*"))) {
+				return offer, true
+			}
+		}
+	}
+	return "", false
+}
+
+var jsonMarshaler = protojson.MarshalOptions{EmitUnpopulated: true}
+
+func writeMessage(w http.ResponseWriter, mediaType string, code int, m proto.Message) {
+	var b []byte
+	var err error
+	switch mediaType {
+	case protobufContentType:
+		b, err = proto.Marshal(m)
+	default:
+		b, err = jsonMarshaler.Marshal(m)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, status.Convert(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	resp := ConsumeResponse{Record: record}
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, code int, st *status.Status) {
+	b, err := jsonMarshaler.Marshal(errorResponse(code, st))
+	if err != nil {
+		http.Error(w, st.Message(), code)
 		return
 	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
 }
 
-type ProduceRequest struct {
-	Record *pb.Record `json:"record"`
+func errorResponse(code int, st *status.Status) *pb.ErrorResponse {
+	return &pb.ErrorResponse{
+		Error: &pb.ErrorResponse_Error{
+			Code:    int32(code),
+			Status:  statusName(st.Code(), code),
+			Message: st.Message(),
+			Details: st.Proto().GetDetails(),
+		},
+	}
 }
 
-type ProduceResponse struct {
-	Offset uint64 `json:"offset"`
+// statusName spells a gRPC code in upper snake case, e.g. PERMISSION_DENIED.
+// ErrOffsetOutOfRange uses its HTTP status as a code, so non-gRPC codes are
+// named after httpStatus.
+func statusName(c codes.Code, httpCode int) string {
+	if c > codes.Unauthenticated {
+		if httpCode == http.StatusNotFound {
+			return "NOT_FOUND"
+		}
+		return "UNKNOWN"
+	}
+	if c == codes.OK {
+		return "OK"
+	}
+
+	var b strings.Builder
+	for i, r := range c.String() {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String())
 }
 
-type ConsumeRequest struct {
-	Offset uint64 `json:"offset"`
+// httpContext carries the subject of the request's client certificate the
+// way authenticate does for gRPC.
+func httpContext(r *http.Request) context.Context {
+	var subject string
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		subject = r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	return context.WithValue(r.Context(), subjectContextKey{}, subject)
 }
 
-type ConsumeResponse struct {
-	Record *pb.Record `json:"record"`
+// httpStatus maps an error of the gRPC server to the HTTP status telling the
+// same, the way grpc-gateway does.
+func httpStatus(err error) int {
+	if errors.As(err, &pb.ErrOffsetOutOfRange{}) {
+		return http.StatusNotFound
+	}
+
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
 }
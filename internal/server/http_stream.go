@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+
+	// wsJSONProtocol and wsProtobufProtocol are the WebSocket subprotocols
+	// records are sent in: protojson in text messages, the default, or
+	// protobuf in binary messages.
+	wsJSONProtocol     = "proglog.v1.json"
+	wsProtobufProtocol = "proglog.v1.protobuf"
+)
+
+var (
+	// heartbeatInterval is how often an idle stream sends a heartbeat, an SSE
+	// comment or a WebSocket ping, so proxies don't close it.
+	heartbeatInterval = 15 * time.Second
+	// tailPollInterval is how often a stream at the end of the log looks for
+	// new records.
+	tailPollInterval = 100 * time.Millisecond
+
+	upgrader = websocket.Upgrader{
+		Subprotocols: []string{wsJSONProtocol, wsProtobufProtocol},
+	}
+)
+
+// handleEvents streams records as Server-Sent Events, each a record event with
+// the record in protojson as data and its offset as id, so EventSource
+// resumes after the last record it got through Last-Event-ID.
+func (s *httpServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, status.New(codes.Internal, "streaming unsupported"))
+		return
+	}
+
+	ctx := httpContext(r)
+	offset, ok := s.startTail(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	// NOTE - nginx 같은 프록시가 이벤트를 모아 두지 않게 한다.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	err := s.tail(ctx, offset, func(record *pb.Record) error {
+		b, err := jsonMarshaler.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: record\ndata: %s\n\n", record.GetOffset(), b); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}, func() error {
+		if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		res := errorResponse(httpStatus(err), status.Convert(err))
+		_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", jsonMarshaler.Format(res))
+		flusher.Flush()
+	}
+}
+
+// handleWebSocket streams records over a WebSocket, one message per record in
+// the negotiated subprotocol, see wsJSONProtocol.
+func (s *httpServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(httpContext(r))
+	defer cancel()
+
+	offset, ok := s.startTail(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// NOTE - Upgrade가 이미 에러 응답을 보냈다.
+		return
+	}
+	defer conn.Close()
+
+	// NOTE - 클라이언트가 보내는 메시지는 쓰지 않지만, pong과 close를 처리하려면 읽어야 한다.
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	messageType, marshal := websocket.TextMessage, jsonMarshaler.Marshal
+	if conn.Subprotocol() == wsProtobufProtocol {
+		messageType, marshal = websocket.BinaryMessage, proto.Marshal
+	}
+
+	err = s.tail(ctx, offset, func(record *pb.Record) error {
+		b, err := marshal(record)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(messageType, b)
+	}, func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(heartbeatInterval))
+	})
+
+	closeCode, reason := websocket.CloseNormalClosure, ""
+	if err != nil && ctx.Err() == nil {
+		closeCode, reason = websocket.CloseInternalServerErr, status.Convert(err).Message()
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason), time.Now().Add(time.Second))
+}
+
+// startTail authorizes the client to consume and returns the offset its
+// stream starts at: right after the Last-Event-ID it resumes from, the from
+// query parameter, or the end of the log. If not ok, it has written the
+// error.
+func (s *httpServer) startTail(w http.ResponseWriter, r *http.Request) (offset uint64, ok bool) {
+	if err := s.Authorizer.Authorize(
+		subject(httpContext(r)),
+		objectWildcard,
+		consumeAction,
+	); err != nil {
+		writeError(w, httpStatus(err), status.Convert(err))
+		return 0, false
+	}
+
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		last, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, status.Newf(codes.InvalidArgument, "invalid Last-Event-ID: %q", id))
+			return 0, false
+		}
+		return last + 1, true
+	}
+
+	if r.URL.Query().Has("from") {
+		from, err := queryUint(r, "from", 0)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()))
+			return 0, false
+		}
+		return from, true
+	}
+
+	return s.nextOffset(), true
+}
+
+// tail sends the records from offset on as they're appended, like
+// ConsumeStream, and a heartbeat whenever the stream was idle for
+// heartbeatInterval. It returns once ctx is done or sending fails.
+func (s *httpServer) tail(ctx context.Context, offset uint64, send func(*pb.Record) error, heartbeat func() error) error {
+	poll := time.NewTicker(tailPollInterval)
+	defer poll.Stop()
+	beat := time.NewTicker(heartbeatInterval)
+	defer beat.Stop()
+
+	for {
+		record, err := s.CommitLog.Read(offset)
+		switch {
+		case err == nil:
+			if err := send(record); err != nil {
+				return err
+			}
+			offset++
+			beat.Reset(heartbeatInterval)
+			continue
+		case errors.As(err, &pb.ErrOffsetOutOfRange{}):
+		default:
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-beat.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case <-poll.C:
+		}
+	}
+}
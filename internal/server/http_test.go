@@ -0,0 +1,421 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/zrma/proglog/internal/auth"
+	"github.com/zrma/proglog/internal/config"
+	"github.com/zrma/proglog/internal/log"
+	"github.com/zrma/proglog/internal/pb"
+)
+
+func TestHTTPServer(t *testing.T) {
+	commitLog, url := setupHTTPServer(t)
+
+	root := newHTTPClient(t, config.RootClientCertFile, config.RootClientKeyFile)
+	nobody := newHTTPClient(t, config.NobodyClientCertFile, config.NobodyClientKeyFile)
+
+	do := func(client *http.Client, method, path, contentType, accept string, body []byte) (*http.Response, []byte) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, url+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, b
+	}
+	requireError := func(res *http.Response, b []byte, code int, name string) {
+		t.Helper()
+
+		require.Equal(t, code, res.StatusCode)
+		require.Equal(t, jsonContentType, res.Header.Get("Content-Type"))
+		var body pb.ErrorResponse
+		require.NoError(t, protojson.Unmarshal(b, &body))
+		require.Equal(t, int32(code), body.GetError().GetCode())
+		require.Equal(t, name, body.GetError().GetStatus())
+		require.NotEmpty(t, body.GetError().GetMessage())
+	}
+
+	res, b := do(root, http.MethodPost, "/v1/records", "", "", []byte(`{"value": "aGVsbG8gd29ybGQ="}`))
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.Equal(t, "/v1/records/0", res.Header.Get("Location"))
+	require.JSONEq(t, `{"offset": "0"}`, string(b), "오프셋 0도 빠뜨리지 않고 보낸다")
+
+	record, err := commitLog.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello world"), record.GetValue(), "HTTP로 쓴 레코드가 CommitLog에 남는다")
+
+	_, err = commitLog.Append(&pb.Record{Value: []byte("from grpc")})
+	require.NoError(t, err)
+
+	res, b = do(root, http.MethodPost, "/v1/records", jsonContentType, "", []byte(`{"records": [{"value": "Mg=="}, {"value": "Mw=="}]}`))
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.JSONEq(t, `{"offsets": ["2", "3"]}`, string(b))
+
+	batch, err := proto.Marshal(&pb.RecordBatch{Records: []*pb.Record{{Value: []byte("4")}}})
+	require.NoError(t, err)
+	res, b = do(root, http.MethodPost, "/v1/records", protobufContentType+"; proto=log.v1.RecordBatch", protobufContentType, batch)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var produced pb.ProduceBatchResponse
+	require.NoError(t, proto.Unmarshal(b, &produced))
+	require.Equal(t, []uint64{4}, produced.GetOffsets())
+
+	res, b = do(root, http.MethodPost, "/v1/records", rawContentType, "", []byte("5"))
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.JSONEq(t, `{"offset": "5"}`, string(b))
+
+	res, b = do(root, http.MethodGet, "/v1/records/1", "", "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var consumed pb.Record
+	require.NoError(t, protojson.Unmarshal(b, &consumed))
+	require.Equal(t, []byte("from grpc"), consumed.GetValue(), "다른 경로로 쓴 레코드도 읽는다")
+
+	res, b = do(root, http.MethodGet, "/v1/records/1", "", "application/octet-stream;q=0.9, text/html", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "from grpc", string(b))
+	require.Equal(t, rawContentType, res.Header.Get("Content-Type"))
+	require.Equal(t, "1", res.Header.Get(offsetHeader))
+
+	res, b = do(root, http.MethodGet, "/v1/records?from=2&limit=3", "", protobufContentType, nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var list pb.ListRecordsResponse
+	require.NoError(t, proto.Unmarshal(b, &list))
+	require.Len(t, list.GetRecords(), 3)
+	require.Equal(t, []byte("2"), list.GetRecords()[0].GetValue())
+	require.Equal(t, uint64(5), list.GetNextOffset())
+
+	res, b = do(root, http.MethodGet, "/v1/records?from=4", "", "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NoError(t, protojson.Unmarshal(b, &list))
+	require.Len(t, list.GetRecords(), 2, "로그 끝에서 멈춘다")
+	require.Equal(t, uint64(6), list.GetNextOffset())
+
+	res, b = do(root, http.MethodGet, "/v1/offsets", "", "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.JSONEq(t, `{"lowestOffset": "0", "highWatermark": "6"}`, string(b))
+
+	res, b = do(root, http.MethodGet, "/v1/records/6", "", "", nil)
+	requireError(res, b, http.StatusNotFound, "NOT_FOUND")
+	res, b = do(root, http.MethodGet, "/v1/records/first", "", "", nil)
+	requireError(res, b, http.StatusBadRequest, "INVALID_ARGUMENT")
+	res, b = do(root, http.MethodGet, "/v1/records?limit=0", "", "", nil)
+	requireError(res, b, http.StatusBadRequest, "INVALID_ARGUMENT")
+	res, b = do(root, http.MethodGet, "/v1/records", "", "text/html", nil)
+	requireError(res, b, http.StatusNotAcceptable, "INVALID_ARGUMENT")
+	res, b = do(root, http.MethodPost, "/v1/records", "text/plain", "", []byte("hello"))
+	requireError(res, b, http.StatusUnsupportedMediaType, "INVALID_ARGUMENT")
+	res, b = do(root, http.MethodPost, "/v1/records", jsonContentType, "", []byte(`{"records": []}`))
+	requireError(res, b, http.StatusBadRequest, "INVALID_ARGUMENT")
+
+	res, b = do(nobody, http.MethodPost, "/v1/records", rawContentType, "", []byte("denied"))
+	requireError(res, b, http.StatusForbidden, "PERMISSION_DENIED")
+	res, b = do(nobody, http.MethodGet, "/v1/records/0", "", "", nil)
+	requireError(res, b, http.StatusForbidden, "PERMISSION_DENIED")
+	res, b = do(nobody, http.MethodGet, "/v1/records", "", "", nil)
+	requireError(res, b, http.StatusForbidden, "PERMISSION_DENIED")
+}
+
+func TestHTTPServer_Gateway(t *testing.T) {
+	commitLog, url := setupHTTPServer(t)
+	root := newHTTPClient(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+	record := &pb.Record{Value: []byte("hello world"), Key: []byte("key")}
+	body, err := proto.Marshal(record)
+	require.NoError(t, err)
+	res, err := root.Post(url+"/v1/records", protobufContentType, bytes.NewReader(body))
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode, "protobuf 레코드 하나는 게이트웨이가 받는다")
+
+	appended, err := commitLog.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, record.GetKey(), appended.GetKey())
+
+	_, err = commitLog.Append(&pb.Record{Value: []byte("again")})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/v1/records:stream?offset=0", nil)
+	require.NoError(t, err)
+	res, err = root.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	lines := bufio.NewScanner(res.Body)
+	for _, want := range []string{"hello world", "again"} {
+		require.True(t, lines.Scan())
+		var line struct {
+			Result json.RawMessage `json:"result"`
+		}
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &line))
+		var consumed pb.ConsumeResponse
+		require.NoError(t, protojson.Unmarshal(line.Result, &consumed))
+		require.Equal(t, want, string(consumed.GetRecord().GetValue()), "한 줄에 레코드 하나씩 흘려보낸다")
+	}
+
+	res, err = root.Get(url + "/v1/openapi.json")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	var openAPI struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&openAPI))
+	require.Contains(t, openAPI.Paths, "/v1/records/{offset}")
+	require.Contains(t, openAPI.Paths, "/v1/records:stream")
+}
+
+func TestHTTPServer_Events(t *testing.T) {
+	setHeartbeatInterval(t, 50*time.Millisecond)
+	commitLog, url := setupHTTPServer(t)
+	root := newHTTPClient(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+	for _, value := range []string{"0", "1"} {
+		_, err := commitLog.Append(&pb.Record{Value: []byte(value)})
+		require.NoError(t, err)
+	}
+
+	open := func(query, lastEventID string) *bufio.Reader {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, url+"/v1/records/events"+query, nil)
+		require.NoError(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		res, err := root.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = res.Body.Close()
+		})
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, eventStreamContentType, res.Header.Get("Content-Type"))
+		return bufio.NewReader(res.Body)
+	}
+	// NOTE - 이벤트 하나(빈 줄로 끝나는 줄들)를 읽는다.
+	next := func(events *bufio.Reader) []string {
+		t.Helper()
+
+		var lines []string
+		for {
+			line, err := events.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				return lines
+			}
+			lines = append(lines, line)
+		}
+	}
+	heartbeat := []string{": heartbeat"}
+	requireRecord := func(events *bufio.Reader, offset uint64, value string) {
+		t.Helper()
+
+		event := next(events)
+		for slices.Equal(event, heartbeat) {
+			event = next(events)
+		}
+		require.Len(t, event, 3)
+		require.Equal(t, fmt.Sprintf("id: %d", offset), event[0])
+		require.Equal(t, "event: record", event[1])
+		var record pb.Record
+		require.NoError(t, protojson.Unmarshal([]byte(strings.TrimPrefix(event[2], "data: ")), &record))
+		require.Equal(t, offset, record.GetOffset())
+		require.Equal(t, value, string(record.GetValue()))
+	}
+
+	events := open("?from=1", "")
+	requireRecord(events, 1, "1")
+
+	_, err := commitLog.Append(&pb.Record{Value: []byte("2")})
+	require.NoError(t, err)
+	requireRecord(events, 2, "2")
+	require.Equal(t, heartbeat, next(events), "쉬는 동안 하트비트를 보낸다")
+
+	tail := open("", "")
+	_, err = commitLog.Append(&pb.Record{Value: []byte("3")})
+	require.NoError(t, err)
+	requireRecord(tail, 3, "3")
+
+	resumed := open("?from=0", "2")
+	requireRecord(resumed, 3, "3")
+
+	nobody := newHTTPClient(t, config.NobodyClientCertFile, config.NobodyClientKeyFile)
+	res, err := nobody.Get(url + "/v1/records/events")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestHTTPServer_WebSocket(t *testing.T) {
+	setHeartbeatInterval(t, 50*time.Millisecond)
+	commitLog, url := setupHTTPServer(t)
+	wsURL := "wss" + strings.TrimPrefix(url, "https") + "/v1/records/ws"
+
+	_, err := commitLog.Append(&pb.Record{Value: []byte("0")})
+	require.NoError(t, err)
+
+	dialer := newWebSocketDialer(t, config.RootClientCertFile, config.RootClientKeyFile)
+	dialer.Subprotocols = []string{wsProtobufProtocol}
+	conn, _, err := dialer.Dial(wsURL+"?from=0", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	require.Equal(t, wsProtobufProtocol, conn.Subprotocol())
+
+	pings := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	requireRecord := func(offset uint64, value string) {
+		t.Helper()
+
+		messageType, b, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, websocket.BinaryMessage, messageType)
+		var record pb.Record
+		require.NoError(t, proto.Unmarshal(b, &record))
+		require.Equal(t, offset, record.GetOffset())
+		require.Equal(t, value, string(record.GetValue()))
+	}
+	requireRecord(0, "0")
+
+	_, err = commitLog.Append(&pb.Record{Value: []byte("1")})
+	require.NoError(t, err)
+	requireRecord(1, "1")
+
+	// NOTE - 핑 핸들러는 메시지를 읽는 동안에만 불린다.
+	go func() {
+		_, _, _ = conn.ReadMessage()
+	}()
+	select {
+	case <-pings:
+	case <-time.After(5 * time.Second):
+		t.Fatal("쉬는 동안 핑을 보낸다")
+	}
+
+	jsonConn, _, err := newWebSocketDialer(t, config.RootClientCertFile, config.RootClientKeyFile).Dial(wsURL, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = jsonConn.Close()
+	})
+	_, err = commitLog.Append(&pb.Record{Value: []byte("2")})
+	require.NoError(t, err)
+	messageType, b, err := jsonConn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, websocket.TextMessage, messageType, "서브프로토콜이 없으면 JSON으로 보낸다")
+	var record pb.Record
+	require.NoError(t, protojson.Unmarshal(b, &record))
+	require.Equal(t, uint64(2), record.GetOffset(), "from이 없으면 로그 끝부터 보낸다")
+
+	_, res, err := newWebSocketDialer(t, config.NobodyClientCertFile, config.NobodyClientKeyFile).Dial(wsURL, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func setupHTTPServer(t *testing.T) (*log.Log, string) {
+	t.Helper()
+
+	commitLog, err := log.NewLog(t.TempDir(), log.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = commitLog.Close()
+	})
+
+	authorizer, err := auth.New(config.ACLModelFile, config.ACLPolicyFile)
+	require.NoError(t, err)
+
+	svr, err := NewHTTPServer("", &Config{
+		CommitLog:  commitLog,
+		Authorizer: authorizer,
+	})
+	require.NoError(t, err)
+
+	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile:      config.ServerCertFile,
+		KeyFile:       config.ServerKeyFile,
+		CAFile:        config.CAFile,
+		ServerAddress: "127.0.0.1",
+		Server:        true,
+	})
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(svr.Handler)
+	ts.TLS = serverTLSConfig
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return commitLog, ts.URL
+}
+
+func setHeartbeatInterval(t *testing.T, d time.Duration) {
+	t.Helper()
+
+	prev := heartbeatInterval
+	heartbeatInterval = d
+	t.Cleanup(func() {
+		heartbeatInterval = prev
+	})
+}
+
+func newWebSocketDialer(t *testing.T, certFile, keyFile string) *websocket.Dialer {
+	t.Helper()
+
+	tlsConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		CAFile:   config.CAFile,
+	})
+	require.NoError(t, err)
+
+	return &websocket.Dialer{TLSClientConfig: tlsConfig}
+}
+
+func newHTTPClient(t *testing.T, certFile, keyFile string) *http.Client {
+	t.Helper()
+
+	tlsConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		CAFile:   config.CAFile,
+	})
+	require.NoError(t, err)
+
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
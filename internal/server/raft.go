@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+const raftAction = "raft"
+
+var _ pb.RaftServer = (*raftServer)(nil)
+
+// raftServer only lets peers allowed the raft action reach the Raft
+// transport, so clients holding produce/consume rights can't forge Raft
+// traffic.
+type raftServer struct {
+	pb.UnimplementedRaftServer
+	*grpcServer
+}
+
+func (s raftServer) AppendEntries(ctx context.Context, req *pb.RaftCommand) (*pb.RaftCommand, error) {
+	if err := s.authorizeRaft(ctx); err != nil {
+		return nil, err
+	}
+	return s.RaftServer.AppendEntries(ctx, req)
+}
+
+func (s raftServer) RequestVote(ctx context.Context, req *pb.RaftCommand) (*pb.RaftCommand, error) {
+	if err := s.authorizeRaft(ctx); err != nil {
+		return nil, err
+	}
+	return s.RaftServer.RequestVote(ctx, req)
+}
+
+func (s raftServer) RequestPreVote(ctx context.Context, req *pb.RaftCommand) (*pb.RaftCommand, error) {
+	if err := s.authorizeRaft(ctx); err != nil {
+		return nil, err
+	}
+	return s.RaftServer.RequestPreVote(ctx, req)
+}
+
+func (s raftServer) TimeoutNow(ctx context.Context, req *pb.RaftCommand) (*pb.RaftCommand, error) {
+	if err := s.authorizeRaft(ctx); err != nil {
+		return nil, err
+	}
+	return s.RaftServer.TimeoutNow(ctx, req)
+}
+
+func (s raftServer) InstallSnapshot(stream pb.Raft_InstallSnapshotServer) error {
+	if err := s.authorizeRaft(stream.Context()); err != nil {
+		return err
+	}
+	return s.RaftServer.InstallSnapshot(stream)
+}
+
+func (s raftServer) authorizeRaft(ctx context.Context) error {
+	return s.Authorizer.Authorize(
+		subject(ctx),
+		objectWildcard,
+		raftAction,
+	)
+}
@@ -2,25 +2,38 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	grpcZap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
 	grpcCtxTags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	"go.opencensus.io/plugin/ocgrpc"
 	"go.opencensus.io/stats/view"
-	"go.opencensus.io/trace"
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/stats"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/go-grpc-middleware/auth"
 
 	"github.com/zrma/proglog/internal/pb"
+	"github.com/zrma/proglog/internal/tracing"
 )
 
 type CommitLog interface {
@@ -33,25 +46,23 @@ type Authorizer interface {
 }
 
 func NewGRPCServer(config *Config, opts ...grpc.ServerOption) (*grpc.Server, error) {
-	logger := zap.L().Named("Server")
+	logger := config.Logger
+	if logger == nil {
+		logger = zap.L()
+	}
+	logger = logger.Named("Server")
 	zapOpts := []grpcZap.Option{
 		grpcZap.WithDurationField(
 			func(duration time.Duration) zapcore.Field {
 				return zap.Int64("grpc.time_ns", duration.Nanoseconds())
 			},
 		),
+		grpcZap.WithDecider(func(fullMethodName string, err error) bool {
+			// NOTE - 하트비트를 포함한 Raft 호출과 로드 밸런서의 헬스 체크는 너무 잦으므로 실패한 경우만 로깅한다.
+			return err != nil || !(isRaftMethod(fullMethodName) || isHealthMethod(fullMethodName))
+		}),
 	}
 
-	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
-	//trace.ApplyConfig(trace.Config{
-	//	DefaultSampler: func(p trace.SamplingParameters) trace.SamplingDecision {
-	//		if strings.Contains(p.Name, "Produce") {
-	//			return trace.SamplingDecision{Sample: true}
-	//		}
-	//		return halfSampler(p)
-	//	},
-	//})
-
 	if err := view.Register(ocgrpc.DefaultServerViews...); err != nil {
 		return nil, err
 	}
@@ -72,7 +83,16 @@ func NewGRPCServer(config *Config, opts ...grpc.ServerOption) (*grpc.Server, err
 				grpc_auth.UnaryServerInterceptor(authenticate),
 			),
 		),
-		grpc.StatsHandler(&ocgrpc.ServerHandler{}),
+		// NOTE - OpenCensus는 지표만 수집하고 트레이싱은 OpenTelemetry가 맡는다.
+		grpc.StatsHandler(&ocgrpc.ServerHandler{
+			StartOptions: octrace.StartOptions{Sampler: octrace.NeverSample()},
+		}),
+		grpc.StatsHandler(otelgrpc.NewServerHandler(
+			// NOTE - Raft 호출은 너무 잦으므로 트레이스하지 않는다.
+			otelgrpc.WithFilter(func(info *stats.RPCTagInfo) bool {
+				return !isRaftMethod(info.FullMethodName)
+			}),
+		)),
 	)
 
 	svc := grpc.NewServer(opts...)
@@ -83,12 +103,84 @@ func NewGRPCServer(config *Config, opts ...grpc.ServerOption) (*grpc.Server, err
 	}
 
 	pb.RegisterLogServer(svc, svr)
+	if config.Membership != nil || config.ReplicationLag != nil {
+		pb.RegisterAdminServer(svc, adminServer{grpcServer: svr})
+	}
+	if config.RaftServer != nil {
+		pb.RegisterRaftServer(svc, raftServer{grpcServer: svr})
+	}
+
+	services := make([]string, 0, len(svc.GetServiceInfo()))
+	for name := range svc.GetServiceInfo() {
+		services = append(services, name)
+	}
+	slices.Sort(services)
+	healthpb.RegisterHealthServer(svc, newHealthServer(config.Readiness, services))
+	// NOTE - grpcurl 같은 도구가 proto 파일 없이도 서비스를 호출할 수 있게 한다.
+	reflection.Register(svc)
 	return svc, nil
 }
 
+func isRaftMethod(fullMethodName string) bool {
+	return strings.HasPrefix(fullMethodName, "/"+pb.Raft_ServiceDesc.ServiceName+"/")
+}
+
+func isHealthMethod(fullMethodName string) bool {
+	return strings.HasPrefix(fullMethodName, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
+
 type Config struct {
 	CommitLog  CommitLog
 	Authorizer Authorizer
+
+	// LeaderLocator, if set, marks this server as part of a replicated
+	// cluster in which only the leader accepts writes.
+	LeaderLocator LeaderLocator
+	// PeerTLSConfig is used to dial the leader when forwarding writes.
+	PeerTLSConfig *tls.Config
+	// DisableForwarding makes followers reject writes with pb.ErrNotLeader
+	// instead of proxying them to the leader.
+	DisableForwarding bool
+	// MaxRecordBytes, if set, makes Produce reject larger encoded records
+	// with codes.InvalidArgument, e.g. when the server's message limit is
+	// raised to carry Raft.
+	MaxRecordBytes int
+
+	// Membership, if set, enables the Admin service.
+	Membership Membership
+	// ReplicationLag, if set, reports replication lag on the Admin service
+	// and enables it without Membership, whose RPCs are then unimplemented.
+	ReplicationLag ReplicationLagReporter
+	// RaftServer, if set, serves Raft traffic between peers over this
+	// server, e.g. a log.GRPCTransport's Server().
+	RaftServer pb.RaftServer
+	// Readiness, if set, decides whether the health service reports this
+	// server as serving; without it the server is always serving.
+	Readiness Readiness
+
+	// Logger logs the RPCs served; it defaults to zap.L().
+	Logger *zap.Logger
+	// LogLevel and Sampler, if set, are the level of Logger and the sampler
+	// of the tracer provider, which the Admin service can change at runtime.
+	LogLevel *zap.AtomicLevel
+	Sampler  *tracing.Sampler
+
+	// NOTE - 같은 설정으로 만든 gRPC와 HTTP 서버는 리더로 가는 연결 하나를 같이 쓴다.
+	forwarderOnce sync.Once
+	forwarder     *forwarder
+}
+
+func (c *Config) leaderForwarder() *forwarder {
+	c.forwarderOnce.Do(func() {
+		c.forwarder = newForwarder(c)
+	})
+	return c.forwarder
+}
+
+// Close closes the connection over which the servers made with the config
+// forward writes to the leader. Call it once they've stopped.
+func (c *Config) Close() error {
+	return c.leaderForwarder().Close()
 }
 
 const (
@@ -102,10 +194,14 @@ var _ pb.LogServer = (*grpcServer)(nil)
 type grpcServer struct {
 	pb.UnimplementedLogServer
 	*Config
+	forwarder *forwarder
 }
 
 func newGrpcServer(config *Config) (*grpcServer, error) {
-	return &grpcServer{Config: config}, nil
+	return &grpcServer{
+		Config:    config,
+		forwarder: config.leaderForwarder(),
+	}, nil
 }
 
 func (s grpcServer) Produce(ctx context.Context, req *pb.ProduceRequest) (*pb.ProduceResponse, error) {
@@ -117,6 +213,27 @@ func (s grpcServer) Produce(ctx context.Context, req *pb.ProduceRequest) (*pb.Pr
 		return nil, err
 	}
 
+	if size := proto.Size(req.GetRecord()); s.MaxRecordBytes > 0 && size > s.MaxRecordBytes {
+		return nil, status.Errorf(codes.InvalidArgument, "record is %d bytes, more than %d", size, s.MaxRecordBytes)
+	}
+
+	// NOTE - Replicator는 origin으로 이미 적용한 레코드를 거르므로 클라이언트가 정한 origin은 지운다.
+	if record := req.GetRecord(); record.GetOrigin() != "" || record.GetOriginOffset() != 0 {
+		if err := s.Authorizer.Authorize(subject(ctx), objectWildcard, raftAction); err != nil {
+			record.Origin, record.OriginOffset = "", 0
+		}
+	}
+
+	if addr, ok := s.leader(); !ok {
+		addr, err := s.forwardTo(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return s.forwarder.Produce(ctx, addr, req)
+	}
+
+	// NOTE - 컨슈머가 자신의 span을 프로듀서의 span에 연결할 수 있도록 레코드에 남긴다.
+	tracing.Inject(ctx, req.Record)
 	offset, err := s.CommitLog.Append(req.Record)
 	if err != nil {
 		return nil, err
@@ -125,6 +242,15 @@ func (s grpcServer) Produce(ctx context.Context, req *pb.ProduceRequest) (*pb.Pr
 	return &pb.ProduceResponse{Offset: offset}, nil
 }
 
+// leader reports whether this server may accept writes and, if not, the
+// leader's address. Servers without a LeaderLocator always accept writes.
+func (s grpcServer) leader() (string, bool) {
+	if s.LeaderLocator == nil {
+		return "", true
+	}
+	return s.LeaderLocator.Leader()
+}
+
 func (s grpcServer) Consume(ctx context.Context, req *pb.ConsumeRequest) (*pb.ConsumeResponse, error) {
 	if err := s.Authorizer.Authorize(
 		subject(ctx),
@@ -139,12 +265,83 @@ func (s grpcServer) Consume(ctx context.Context, req *pb.ConsumeRequest) (*pb.Co
 		return nil, err
 	}
 
-	return &pb.ConsumeResponse{Record: record}, nil
+	if link := tracing.Link(record); link.SpanContext.IsValid() {
+		trace.SpanFromContext(ctx).AddLink(link)
+	}
+
+	res := &pb.ConsumeResponse{Record: record}
+	if l, ok := s.CommitLog.(offsetRange); ok {
+		// NOTE - 방금 레코드를 읽었으므로 로그가 비어 있지 않다.
+		if highest, err := l.HighestOffset(); err == nil {
+			res.HighWatermark = highest + 1
+		}
+	}
+	return res, nil
+}
+
+// offsetRange is implemented by commit logs that can tell their newest
+// offset, letting Consume report a high watermark.
+type offsetRange interface {
+	HighestOffset() (uint64, error)
+}
+
+// lowestOffset is implemented by commit logs that can tell their oldest
+// offset, which isn't 0 once they were truncated.
+type lowestOffset interface {
+	LowestOffset() (uint64, error)
+}
+
+func (s grpcServer) GetOffsets(ctx context.Context, _ *pb.GetOffsetsRequest) (*pb.GetOffsetsResponse, error) {
+	if err := s.Authorizer.Authorize(
+		subject(ctx),
+		objectWildcard,
+		consumeAction,
+	); err != nil {
+		return nil, err
+	}
+
+	res := &pb.GetOffsetsResponse{HighWatermark: s.nextOffset()}
+	if l, ok := s.CommitLog.(lowestOffset); ok {
+		lowest, err := l.LowestOffset()
+		if err != nil {
+			return nil, err
+		}
+		res.LowestOffset = lowest
+	}
+	return res, nil
+}
+
+// nextOffset returns the offset the next record appended gets, or 0 if the
+// commit log can't tell.
+func (s grpcServer) nextOffset() uint64 {
+	l, ok := s.CommitLog.(offsetRange)
+	if !ok {
+		return 0
+	}
+	highest, err := l.HighestOffset()
+	if err != nil {
+		return 0
+	}
+	// NOTE - 빈 로그도 HighestOffset이 0이므로 실제로 읽히는지 확인한다.
+	if _, err := s.CommitLog.Read(highest); err == nil {
+		return highest + 1
+	}
+	// NOTE - 모두 잘라 낸 로그는 HighestOffset이 잘라 낸 마지막 레코드를 가리키므로 LowestOffset부터 쓴다.
+	if l, ok := s.CommitLog.(lowestOffset); ok {
+		if lowest, err := l.LowestOffset(); err == nil {
+			return max(highest, lowest)
+		}
+	}
+	return highest
 }
 
 func (s grpcServer) ProduceStream(stream pb.Log_ProduceStreamServer) error {
 	for {
 		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			// NOTE - 클라이언트가 다 보냈으면 에러 없이 스트림을 끝낸다.
+			return nil
+		}
 		if err != nil {
 			return err
 		}
@@ -160,27 +357,39 @@ func (s grpcServer) ProduceStream(stream pb.Log_ProduceStreamServer) error {
 	}
 }
 
+// ConsumeStream sends the records from the requested offset on as they're
+// appended. At the end of the log it looks for new records every
+// tailPollInterval, like tail, rather than spinning on Consume.
 func (s grpcServer) ConsumeStream(req *pb.ConsumeRequest, stream pb.Log_ConsumeStreamServer) error {
+	poll := time.NewTicker(tailPollInterval)
+	defer poll.Stop()
+
 	for {
 		select {
 		case <-stream.Context().Done():
 			return nil
 		default:
-			res, err := s.Consume(stream.Context(), req)
-			switch err.(type) {
-			case nil:
-			case pb.ErrOffsetOutOfRange:
-				continue
-			default:
-				return err
-			}
+		}
 
-			if err := stream.Send(res); err != nil {
-				return err
+		res, err := s.Consume(stream.Context(), req)
+		switch err.(type) {
+		case nil:
+		case pb.ErrOffsetOutOfRange:
+			select {
+			case <-stream.Context().Done():
+				return nil
+			case <-poll.C:
 			}
+			continue
+		default:
+			return err
+		}
 
-			req.Offset++
+		if err := stream.Send(res); err != nil {
+			return err
 		}
+
+		req.Offset++
 	}
 }
 
@@ -194,7 +403,11 @@ func authenticate(ctx context.Context) (context.Context, error) {
 		return context.WithValue(ctx, subjectContextKey{}, ""), nil
 	}
 
-	tlsInfo := p.AuthInfo.(credentials.TLSInfo)
+	// NOTE - 클라이언트 인증서를 요구하지 않는 TLS 설정이면 검증된 체인이 없다.
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return ctx, status.New(codes.Unauthenticated, "no verified client certificate").Err()
+	}
 	subject := tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
 
 	return context.WithValue(ctx, subjectContextKey{}, subject), nil
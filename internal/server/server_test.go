@@ -2,25 +2,36 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"flag"
+	"io"
 	"net"
 	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.opencensus.io/examples/exporter"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
 	"google.golang.org/grpc/status"
 
 	"github.com/zrma/proglog/internal/auth"
 	"github.com/zrma/proglog/internal/config"
 	"github.com/zrma/proglog/internal/log"
 	"github.com/zrma/proglog/internal/pb"
+	"github.com/zrma/proglog/internal/tracing"
 )
 
 var debug = flag.Bool("debug", false, "Enable observability for debugging.")
@@ -55,6 +66,36 @@ func TestGRPCServer_ProduceAndConsume(t *testing.T) {
 
 		require.Equal(t, want.GetValue(), consume.GetRecord().GetValue())
 		require.Equal(t, produce.GetOffset(), consume.GetRecord().GetOffset())
+		require.Equal(t, produce.GetOffset()+1, consume.GetHighWatermark())
+	})
+
+	t.Run("OK/Origin", func(t *testing.T) {
+		ctx := context.Background()
+		produce := func(t *testing.T, f *fixture) *pb.Record {
+			t.Helper()
+
+			res, err := f.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{
+				Value:        []byte("hello world"),
+				Origin:       "../../node-1",
+				OriginOffset: 42,
+			}})
+			require.NoError(t, err)
+			consume, err := f.client.Consume(ctx, &pb.ConsumeRequest{Offset: res.GetOffset()})
+			require.NoError(t, err)
+			return consume.GetRecord()
+		}
+
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+		record := produce(t, f)
+		require.Equal(t, "../../node-1", record.GetOrigin(), "피어는 복제한 레코드의 origin을 남긴다")
+		require.Equal(t, uint64(42), record.GetOriginOffset())
+
+		f = newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.Authorizer = denyAction{Authorizer: cfg.Authorizer, action: raftAction}
+		})
+		record = produce(t, f)
+		require.Empty(t, record.GetOrigin(), "피어가 아니면 origin을 정할 수 없다")
+		require.Zero(t, record.GetOriginOffset())
 	})
 
 	t.Run("Err/NobodyClient", func(t *testing.T) {
@@ -80,6 +121,51 @@ func TestGRPCServer_ProduceAndConsume(t *testing.T) {
 	})
 }
 
+func TestGRPCServer_Unauthenticated(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	diskLog, err := log.NewLog(t.TempDir(), log.Config{})
+	require.NoError(t, err)
+	defer func() { _ = diskLog.Close() }()
+	authorizer, err := auth.New(config.ACLModelFile, config.ACLPolicyFile)
+	require.NoError(t, err)
+
+	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile: config.ServerCertFile,
+		KeyFile:  config.ServerKeyFile,
+		CAFile:   config.CAFile,
+		Server:   true,
+	})
+	require.NoError(t, err)
+	// NOTE - 클라이언트 인증서를 요구하지 않는 서버에 인증서 없이 붙는다.
+	serverTLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+
+	svr, err := NewGRPCServer(&Config{CommitLog: diskLog, Authorizer: authorizer}, grpc.Creds(credentials.NewTLS(serverTLSConfig)))
+	require.NoError(t, err)
+	go func() {
+		_ = svr.Serve(l)
+	}()
+	defer svr.Stop()
+
+	client := pb.NewLogClient(newConn(t, l.Addr().String(), "", ""))
+	_, err = client.Produce(context.Background(), &pb.ProduceRequest{Record: &pb.Record{Value: []byte("hello world")}})
+	require.Equal(t, codes.Unauthenticated, status.Code(err), "인증서가 없는 클라이언트는 핸들러까지 가지 않는다")
+}
+
+func TestGRPCServer_MaxRecordBytes(t *testing.T) {
+	f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+		cfg.MaxRecordBytes = 16
+	})
+
+	ctx := context.Background()
+	_, err := f.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("small")}})
+	require.NoError(t, err)
+
+	_, err = f.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("more than sixteen bytes")}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err), "제한보다 큰 레코드는 쓰지 않는다")
+}
+
 func TestGRPCServer_ConsumePastBoundary(t *testing.T) {
 	f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
 
@@ -97,6 +183,41 @@ func TestGRPCServer_ConsumePastBoundary(t *testing.T) {
 	require.Equal(t, want, got)
 }
 
+func TestGRPCServer_GetOffsets(t *testing.T) {
+	t.Run("OK/RootClient", func(t *testing.T) {
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+		ctx := context.Background()
+
+		res, err := f.client.GetOffsets(ctx, &pb.GetOffsetsRequest{})
+		require.NoError(t, err)
+		require.Zero(t, res.GetLowestOffset())
+		require.Zero(t, res.GetHighWatermark(), "빈 로그")
+
+		for _, value := range []string{"foo", "bar"} {
+			_, err := f.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte(value)}})
+			require.NoError(t, err)
+		}
+		res, err = f.client.GetOffsets(ctx, &pb.GetOffsetsRequest{})
+		require.NoError(t, err)
+		require.Zero(t, res.GetLowestOffset())
+		require.Equal(t, uint64(2), res.GetHighWatermark())
+
+		require.NoError(t, f.cfg.CommitLog.(*log.Log).Truncate(1))
+		res, err = f.client.GetOffsets(ctx, &pb.GetOffsetsRequest{})
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), res.GetLowestOffset())
+		require.Equal(t, uint64(2), res.GetHighWatermark(), "모두 잘라 낸 로그도 다음 오프셋을 안다")
+	})
+
+	t.Run("Err/NobodyClient", func(t *testing.T) {
+		f := newFixture(t, config.NobodyClientCertFile, config.NobodyClientKeyFile)
+
+		_, err := f.client.GetOffsets(context.Background(), &pb.GetOffsetsRequest{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
 func TestGrpcServer_Stream_ProduceAndConsume(t *testing.T) {
 	t.Run("OK/RootClient", func(t *testing.T) {
 		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
@@ -121,6 +242,10 @@ func TestGrpcServer_Stream_ProduceAndConsume(t *testing.T) {
 				require.NoError(t, err)
 				require.Equal(t, uint64(offset), resp.GetOffset())
 			}
+
+			require.NoError(t, stream.CloseSend())
+			_, err = stream.Recv()
+			require.ErrorIs(t, err, io.EOF, "다 보내고 닫으면 스트림이 에러 없이 끝난다")
 		}
 
 		{
@@ -131,16 +256,36 @@ func TestGrpcServer_Stream_ProduceAndConsume(t *testing.T) {
 				resp, err := stream.Recv()
 				require.NoError(t, err)
 
+				// NOTE - 트레이싱이 켜져 있으면 헤더에 트레이스 컨텍스트가 더해진다.
 				got := resp.GetRecord()
-				require.Equal(t, &pb.Record{
-					Value:  record.GetValue(),
-					Offset: record.GetOffset(),
-				}, got)
+				require.Equal(t, record.GetValue(), got.GetValue())
 				require.Equal(t, uint64(offset), got.GetOffset())
 			}
 		}
 	})
 
+	t.Run("OK/Tail", func(t *testing.T) {
+		reads := &countingLog{}
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			reads.CommitLog = cfg.CommitLog
+			cfg.CommitLog = reads
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		stream, err := f.client.ConsumeStream(ctx, &pb.ConsumeRequest{Offset: 0})
+		require.NoError(t, err)
+
+		time.AfterFunc(3*tailPollInterval, func() {
+			_, _ = f.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("late")}})
+		})
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, []byte("late"), res.GetRecord().GetValue(), "나중에 쓴 레코드도 받는다")
+		require.Less(t, reads.count.Load(), int64(10), "로그 끝에서는 Read를 돌리지 않고 폴링한다")
+	})
+
 	t.Run("Err/NobodyClient", func(t *testing.T) {
 		f := newFixture(t, config.NobodyClientCertFile, config.NobodyClientKeyFile)
 
@@ -180,12 +325,601 @@ func TestGrpcServer_Stream_ProduceAndConsume(t *testing.T) {
 	})
 }
 
+func TestGRPCServer_ProduceOnFollower(t *testing.T) {
+	peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+		CertFile: config.RootClientCertFile,
+		KeyFile:  config.RootClientKeyFile,
+		CAFile:   config.CAFile,
+	})
+	require.NoError(t, err)
+
+	t.Run("OK/Forward", func(t *testing.T) {
+		leader := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+		follower := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.LeaderLocator = fakeLeaderLocator{addr: leader.addr}
+			cfg.PeerTLSConfig = peerTLSConfig
+		})
+
+		ctx := context.Background()
+
+		want := &pb.Record{
+			Value: []byte("hello world"),
+		}
+
+		produce, err := follower.client.Produce(ctx, &pb.ProduceRequest{Record: want})
+		require.NoError(t, err)
+
+		consume, err := leader.client.Consume(ctx, &pb.ConsumeRequest{Offset: produce.GetOffset()})
+		require.NoError(t, err)
+		require.Equal(t, want.GetValue(), consume.GetRecord().GetValue())
+
+		_, err = follower.client.Consume(ctx, &pb.ConsumeRequest{Offset: produce.GetOffset()})
+		require.Error(t, err, "follower의 로컬 로그에는 기록되지 않아야 함")
+
+		stream, err := follower.client.ProduceStream(ctx)
+		require.NoError(t, err)
+
+		err = stream.Send(&pb.ProduceRequest{Record: want})
+		require.NoError(t, err)
+
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, produce.GetOffset()+1, resp.GetOffset())
+
+		forwarder := follower.cfg.leaderForwarder()
+		require.NotNil(t, forwarder.cc, "리더로 가는 연결을 재사용한다")
+		require.NoError(t, follower.cfg.Close())
+		require.Nil(t, forwarder.cc, "닫으면 리더로 가는 연결을 닫는다")
+
+		produce, err = follower.client.Produce(ctx, &pb.ProduceRequest{Record: want})
+		require.NoError(t, err, "닫은 뒤에 쓰면 리더에 다시 연결한다")
+		require.Equal(t, resp.GetOffset()+1, produce.GetOffset())
+	})
+
+	t.Run("Err/NotLeader", func(t *testing.T) {
+		follower := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.LeaderLocator = fakeLeaderLocator{addr: "127.0.0.1:1234"}
+			cfg.DisableForwarding = true
+		})
+
+		ctx := context.Background()
+
+		produce, err := follower.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("hello world")}})
+		require.Error(t, err)
+		require.Nil(t, produce)
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+		addr, ok := pb.LeaderAddrFromError(err)
+		require.True(t, ok)
+		require.Equal(t, "127.0.0.1:1234", addr)
+	})
+
+	t.Run("Err/NoLeader", func(t *testing.T) {
+		follower := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.LeaderLocator = fakeLeaderLocator{}
+			cfg.PeerTLSConfig = peerTLSConfig
+		})
+
+		ctx := context.Background()
+
+		_, err := follower.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("hello world")}})
+		require.Error(t, err)
+
+		addr, ok := pb.LeaderAddrFromError(err)
+		require.True(t, ok)
+		require.Empty(t, addr)
+	})
+
+	t.Run("Err/ForwardedTwice", func(t *testing.T) {
+		leader := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+		// NOTE - 선거 중처럼 포워딩 받은 노드도 리더가 아니라고 알고 있다.
+		stale := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.LeaderLocator = fakeLeaderLocator{addr: leader.addr}
+			cfg.PeerTLSConfig = peerTLSConfig
+		})
+		follower := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.LeaderLocator = fakeLeaderLocator{addr: stale.addr}
+			cfg.PeerTLSConfig = peerTLSConfig
+		})
+
+		ctx := context.Background()
+
+		_, err := follower.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("hello world")}})
+		require.Equal(t, codes.Unavailable, status.Code(err), "포워딩 받은 요청은 다시 포워딩하지 않는다")
+
+		_, err = leader.client.Consume(ctx, &pb.ConsumeRequest{Offset: 0})
+		require.Error(t, err)
+
+		_, err = stale.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("hello world")}})
+		require.NoError(t, err, "클라이언트가 직접 보낸 요청은 포워딩한다")
+	})
+}
+
+func TestGRPCServer_Tracing(t *testing.T) {
+	if *debug {
+		t.Skip("-debug installs its own tracer provider")
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := tracing.New(context.Background(), tracing.Config{
+		Exporters: []sdktrace.SpanExporter{exporter},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+	})
+
+	f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+	ctx := context.Background()
+	produce, err := f.client.Produce(ctx, &pb.ProduceRequest{Record: &pb.Record{Value: []byte("hello world")}})
+	require.NoError(t, err)
+	consume, err := f.client.Consume(ctx, &pb.ConsumeRequest{Offset: produce.GetOffset()})
+	require.NoError(t, err)
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	produceSpan, ok := spans["log.v1.Log/Produce"]
+	require.True(t, ok)
+	consumeSpan, ok := spans["log.v1.Log/Consume"]
+	require.True(t, ok)
+
+	link := tracing.Link(consume.GetRecord())
+	require.Equal(t, produceSpan.SpanContext.SpanID(), link.SpanContext.SpanID(), "레코드에 프로듀서의 span이 남는다")
+
+	require.Len(t, consumeSpan.Links, 1)
+	require.Equal(t, produceSpan.SpanContext.TraceID(), consumeSpan.Links[0].SpanContext.TraceID())
+	require.Equal(t, produceSpan.SpanContext.SpanID(), consumeSpan.Links[0].SpanContext.SpanID(), "컨슈머의 span이 프로듀서의 span에 연결된다")
+}
+
+func TestGRPCServer_Admin(t *testing.T) {
+	t.Run("OK/RootClient", func(t *testing.T) {
+		membership := &fakeMembership{}
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.Membership = membership
+		})
+
+		ctx := context.Background()
+
+		_, err := f.admin.Join(ctx, &pb.JoinRequest{Id: "voter", RpcAddr: "127.0.0.1:1"})
+		require.NoError(t, err)
+
+		_, err = f.admin.Join(ctx, &pb.JoinRequest{Id: "replica", RpcAddr: "127.0.0.1:2", Nonvoter: true})
+		require.NoError(t, err)
+
+		res, err := f.admin.GetServers(ctx, &pb.GetServersRequest{})
+		require.NoError(t, err)
+		require.Len(t, res.GetServers(), 2)
+		require.Equal(t, "voter", res.GetServers()[0].GetId())
+		require.False(t, res.GetServers()[0].GetNonvoter())
+		require.Equal(t, "replica", res.GetServers()[1].GetId())
+		require.True(t, res.GetServers()[1].GetNonvoter())
+
+		_, err = f.admin.TransferLeadership(ctx, &pb.TransferLeadershipRequest{Id: "voter"})
+		require.NoError(t, err)
+
+		res, err = f.admin.GetServers(ctx, &pb.GetServersRequest{})
+		require.NoError(t, err)
+		require.True(t, res.GetServers()[0].GetIsLeader())
+
+		_, err = f.admin.Leave(ctx, &pb.LeaveRequest{Id: "voter"})
+		require.NoError(t, err)
+
+		res, err = f.admin.GetServers(ctx, &pb.GetServersRequest{})
+		require.NoError(t, err)
+		require.Len(t, res.GetServers(), 1)
+	})
+
+	t.Run("OK/Forward", func(t *testing.T) {
+		membership := &fakeMembership{}
+		leader := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.Membership = membership
+		})
+
+		peerTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
+			CertFile: config.RootClientCertFile,
+			KeyFile:  config.RootClientKeyFile,
+			CAFile:   config.CAFile,
+		})
+		require.NoError(t, err)
+
+		follower := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.Membership = &fakeMembership{}
+			cfg.LeaderLocator = fakeLeaderLocator{addr: leader.addr}
+			cfg.PeerTLSConfig = peerTLSConfig
+		})
+
+		_, err = follower.admin.Join(context.Background(), &pb.JoinRequest{Id: "voter", RpcAddr: "127.0.0.1:1"})
+		require.NoError(t, err)
+
+		servers, err := membership.GetServers()
+		require.NoError(t, err)
+		require.Len(t, servers, 1)
+	})
+
+	t.Run("OK/ReplicationLag", func(t *testing.T) {
+		lags := []*pb.ReplicationLag{{Peer: "follower", Kind: "raft", Lag: 3}}
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.Membership = &fakeMembership{}
+			cfg.ReplicationLag = fakeLagReporter(lags)
+		})
+
+		res, err := f.admin.GetReplicationLag(context.Background(), &pb.GetReplicationLagRequest{})
+		require.NoError(t, err)
+		require.Len(t, res.GetLags(), 1)
+		require.Equal(t, "follower", res.GetLags()[0].GetPeer())
+		require.Equal(t, uint64(3), res.GetLags()[0].GetLag())
+	})
+
+	t.Run("OK/Telemetry", func(t *testing.T) {
+		level := zap.NewAtomicLevelAt(zap.InfoLevel)
+		sampler, err := tracing.NewSampler(tracing.SamplingConfig{Ratio: 1})
+		require.NoError(t, err)
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.Membership = &fakeMembership{}
+			cfg.LogLevel = &level
+			cfg.Sampler = sampler
+		})
+
+		ctx := context.Background()
+
+		res, err := f.admin.GetTelemetry(ctx, &pb.GetTelemetryRequest{})
+		require.NoError(t, err)
+		require.Equal(t, "info", res.GetTelemetry().GetLogLevel())
+		require.Equal(t, 1.0, res.GetTelemetry().GetSampling().GetRatio())
+
+		set, err := f.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: &pb.Telemetry{LogLevel: "warn"}})
+		require.NoError(t, err)
+		require.Equal(t, "warn", set.GetTelemetry().GetLogLevel())
+		require.Equal(t, zap.WarnLevel, level.Level())
+		require.Equal(t, 1.0, set.GetTelemetry().GetSampling().GetRatio(), "지정하지 않은 설정은 그대로 둔다")
+
+		set, err = f.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: &pb.Telemetry{
+			Sampling: &pb.TraceSampling{
+				Ratio:        0.1,
+				MethodRatios: map[string]float64{"log.v1.Log/Produce": 1},
+				SampleErrors: true,
+			},
+		}})
+		require.NoError(t, err)
+		require.Equal(t, "warn", set.GetTelemetry().GetLogLevel())
+		require.Equal(t, tracing.SamplingConfig{
+			Ratio:        0.1,
+			MethodRatios: map[string]float64{"log.v1.Log/Produce": 1},
+			SampleErrors: true,
+		}, sampler.Config())
+
+		_, err = f.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: &pb.Telemetry{LogLevel: "loud"}})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = f.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: &pb.Telemetry{
+			Sampling: &pb.TraceSampling{Ratio: 2},
+		}})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		require.Equal(t, 0.1, sampler.Config().Ratio, "잘못된 설정은 적용하지 않는다")
+	})
+
+	t.Run("Err/TelemetryNotConfigurable", func(t *testing.T) {
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.Membership = &fakeMembership{}
+		})
+
+		ctx := context.Background()
+
+		res, err := f.admin.GetTelemetry(ctx, &pb.GetTelemetryRequest{})
+		require.NoError(t, err)
+		require.Empty(t, res.GetTelemetry().GetLogLevel())
+		require.Nil(t, res.GetTelemetry().GetSampling())
+
+		_, err = f.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: &pb.Telemetry{LogLevel: "warn"}})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		_, err = f.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: &pb.Telemetry{
+			Sampling: &pb.TraceSampling{Ratio: 1},
+		}})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("Err/NobodyClient", func(t *testing.T) {
+		f := newFixture(t, config.NobodyClientCertFile, config.NobodyClientKeyFile, func(cfg *Config) {
+			cfg.Membership = &fakeMembership{}
+		})
+
+		ctx := context.Background()
+
+		_, err := f.admin.Join(ctx, &pb.JoinRequest{Id: "voter", RpcAddr: "127.0.0.1:1"})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = f.admin.Leave(ctx, &pb.LeaveRequest{Id: "voter"})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = f.admin.GetServers(ctx, &pb.GetServersRequest{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = f.admin.TransferLeadership(ctx, &pb.TransferLeadershipRequest{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = f.admin.GetReplicationLag(ctx, &pb.GetReplicationLagRequest{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = f.admin.GetTelemetry(ctx, &pb.GetTelemetryRequest{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = f.admin.SetTelemetry(ctx, &pb.SetTelemetryRequest{Telemetry: &pb.Telemetry{LogLevel: "debug"}})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Err/Disabled", func(t *testing.T) {
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+		_, err := f.admin.GetServers(context.Background(), &pb.GetServersRequest{})
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+}
+
+func TestGRPCServer_Raft(t *testing.T) {
+	t.Run("OK/RootClient", func(t *testing.T) {
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile, func(cfg *Config) {
+			cfg.RaftServer = fakeRaftServer{}
+		})
+
+		ctx := context.Background()
+
+		res, err := f.raft.AppendEntries(ctx, &pb.RaftCommand{Payload: []byte("entries")})
+		require.NoError(t, err)
+		require.Equal(t, []byte("entries"), res.GetPayload())
+
+		stream, err := f.raft.InstallSnapshot(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.RaftSnapshotChunk{Command: []byte("snapshot")}))
+		res, err = stream.CloseAndRecv()
+		require.NoError(t, err)
+		require.Equal(t, []byte("snapshot"), res.GetPayload())
+	})
+
+	t.Run("Err/NobodyClient", func(t *testing.T) {
+		f := newFixture(t, config.NobodyClientCertFile, config.NobodyClientKeyFile, func(cfg *Config) {
+			cfg.RaftServer = fakeRaftServer{}
+		})
+
+		ctx := context.Background()
+
+		_, err := f.raft.RequestVote(ctx, &pb.RaftCommand{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err), "Raft 권한이 없으면 투표 요청을 보낼 수 없다")
+
+		stream, err := f.raft.InstallSnapshot(ctx)
+		require.NoError(t, err)
+		_, err = stream.CloseAndRecv()
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Err/Disabled", func(t *testing.T) {
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+		_, err := f.raft.AppendEntries(context.Background(), &pb.RaftCommand{})
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+}
+
+func TestGRPCServer_Health(t *testing.T) {
+	t.Run("OK/Readiness", func(t *testing.T) {
+		defer func(interval time.Duration) { healthPollInterval = interval }(healthPollInterval)
+		healthPollInterval = 10 * time.Millisecond
+
+		readiness := &fakeReadiness{}
+		readiness.set(errors.New("no leader"))
+		// NOTE - 헬스 체크는 권한을 확인하지 않으므로 nobody도 호출할 수 있다.
+		f := newFixture(t, config.NobodyClientCertFile, config.NobodyClientKeyFile, func(cfg *Config) {
+			cfg.Readiness = readiness
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		for _, service := range []string{"", pb.Log_ServiceDesc.ServiceName} {
+			res, err := f.health.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			require.NoError(t, err)
+			require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.GetStatus(), "리더가 없으면 서비스하지 않는다")
+		}
+
+		stream, err := f.health.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+
+		readiness.set(nil)
+		res, err = stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus(), "준비되면 바뀐 상태를 알린다")
+
+		list, err := f.health.List(ctx, &healthpb.HealthListRequest{})
+		require.NoError(t, err)
+		require.Contains(t, list.GetStatuses(), pb.Log_ServiceDesc.ServiceName)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, list.GetStatuses()[""].GetStatus())
+	})
+
+	t.Run("OK/NoReadiness", func(t *testing.T) {
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+		res, err := f.health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+	})
+
+	t.Run("Err/UnknownService", func(t *testing.T) {
+		f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := f.health.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		stream, err := f.health.Watch(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+		require.NoError(t, err)
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVICE_UNKNOWN, res.GetStatus())
+	})
+}
+
+func TestGRPCServer_Reflection(t *testing.T) {
+	f := newFixture(t, config.RootClientCertFile, config.RootClientKeyFile)
+
+	stream, err := reflectionpb.NewServerReflectionClient(f.conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.NoError(t, stream.CloseSend())
+
+	var services []string
+	for _, service := range res.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	require.Contains(t, services, pb.Log_ServiceDesc.ServiceName)
+	require.Contains(t, services, healthpb.Health_ServiceDesc.ServiceName)
+}
+
+type fakeReadiness struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (r *fakeReadiness) set(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.err = err
+}
+
+func (r *fakeReadiness) Ready() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+type fakeRaftServer struct {
+	pb.UnimplementedRaftServer
+}
+
+func (fakeRaftServer) AppendEntries(_ context.Context, req *pb.RaftCommand) (*pb.RaftCommand, error) {
+	return req, nil
+}
+
+func (fakeRaftServer) RequestVote(_ context.Context, req *pb.RaftCommand) (*pb.RaftCommand, error) {
+	return req, nil
+}
+
+func (fakeRaftServer) InstallSnapshot(stream pb.Raft_InstallSnapshotServer) error {
+	chunk, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&pb.RaftCommand{Payload: chunk.GetCommand()})
+}
+
+type fakeMembership struct {
+	mu      sync.Mutex
+	servers []*pb.Server
+}
+
+func (m *fakeMembership) Join(id, addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.servers = append(m.servers, &pb.Server{Id: id, RpcAddr: addr})
+	return nil
+}
+
+func (m *fakeMembership) JoinNonvoter(id, addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.servers = append(m.servers, &pb.Server{Id: id, RpcAddr: addr, Nonvoter: true})
+	return nil
+}
+
+func (m *fakeMembership) Leave(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.servers = slices.DeleteFunc(m.servers, func(s *pb.Server) bool {
+		return s.GetId() == id
+	})
+	return nil
+}
+
+func (m *fakeMembership) GetServers() ([]*pb.Server, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.servers), nil
+}
+
+func (m *fakeMembership) TransferLeadership(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.servers {
+		s.IsLeader = s.GetId() == id
+	}
+	return nil
+}
+
+type fakeLagReporter []*pb.ReplicationLag
+
+func (r fakeLagReporter) ReplicationLag() []*pb.ReplicationLag {
+	return r
+}
+
+// denyAction denies action to everyone and leaves the rest to Authorizer.
+type denyAction struct {
+	Authorizer
+	action string
+}
+
+func (a denyAction) Authorize(subject, object, action string) error {
+	if action == a.action {
+		return status.Errorf(codes.PermissionDenied, "%s not permitted to %s %s", subject, action, object)
+	}
+	return a.Authorizer.Authorize(subject, object, action)
+}
+
+type fakeLeaderLocator struct {
+	addr string
+}
+
+func (l fakeLeaderLocator) Leader() (string, bool) {
+	return l.addr, false
+}
+
+// countingLog counts the reads of the CommitLog it wraps.
+type countingLog struct {
+	CommitLog
+	count atomic.Int64
+}
+
+func (l *countingLog) Read(off uint64) (*pb.Record, error) {
+	l.count.Add(1)
+	return l.CommitLog.Read(off)
+}
+
 type fixture struct {
 	client pb.LogClient
+	admin  pb.AdminClient
+	raft   pb.RaftClient
+	health healthpb.HealthClient
+	conn   *grpc.ClientConn
 	cfg    *Config
+	addr   string
 }
 
-func newFixture(t *testing.T, cliCert, cliKey string) *fixture {
+func newFixture(t *testing.T, cliCert, cliKey string, fns ...func(*Config)) *fixture {
 	t.Helper()
 
 	flushTelemetry := startTelemetryExporter(t)
@@ -206,6 +940,9 @@ func newFixture(t *testing.T, cliCert, cliKey string) *fixture {
 		CommitLog:  diskLog,
 		Authorizer: authorizer,
 	}
+	for _, fn := range fns {
+		fn(cfg)
+	}
 
 	serverTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
 		CertFile:      config.ServerCertFile,
@@ -228,6 +965,7 @@ func newFixture(t *testing.T, cliCert, cliKey string) *fixture {
 
 	t.Cleanup(func() {
 		svr.GracefulStop()
+		require.NoError(t, cfg.Close())
 		err := l.Close()
 		require.Error(t, err)
 		require.True(t, errors.Is(err, net.ErrClosed), "because svr.GracefulStop closed the listener")
@@ -236,9 +974,16 @@ func newFixture(t *testing.T, cliCert, cliKey string) *fixture {
 		flushTelemetry()
 	})
 
+	conn := newConn(t, l.Addr().String(), cliCert, cliKey)
+
 	return &fixture{
-		client: newClient(t, l.Addr().String(), cliCert, cliKey),
+		client: pb.NewLogClient(conn),
+		admin:  pb.NewAdminClient(conn),
+		raft:   pb.NewRaftClient(conn),
+		health: healthpb.NewHealthClient(conn),
+		conn:   conn,
 		cfg:    cfg,
+		addr:   l.Addr().String(),
 	}
 }
 
@@ -255,28 +1000,38 @@ func startTelemetryExporter(t *testing.T) func() {
 	require.NoError(t, err)
 	t.Logf("traces log file: %s", tracesLogFile.Name())
 
+	// NOTE - 지표는 OpenCensus, 트레이스는 OpenTelemetry로 내보낸다.
 	telemetryExporter, err := exporter.NewLogExporter(exporter.Options{
 		MetricsLogFile:    metricsLogFile.Name(),
-		TracesLogFile:     tracesLogFile.Name(),
+		TracesLogFile:     os.DevNull,
 		ReportingInterval: time.Second,
 	})
 	require.NoError(t, err)
 
 	require.NoError(t, telemetryExporter.Start())
 
+	traceExporter, err := stdouttrace.New(stdouttrace.WithWriter(tracesLogFile))
+	require.NoError(t, err)
+	tp, err := tracing.New(context.Background(), tracing.Config{
+		Exporters: []sdktrace.SpanExporter{traceExporter},
+	})
+	require.NoError(t, err)
+
 	return func() {
 		time.Sleep(1_500 * time.Millisecond)
 		telemetryExporter.Stop()
 		telemetryExporter.Close()
+		_ = tp.Shutdown(context.Background())
+		_ = tracesLogFile.Close()
 	}
 }
 
-func newClient(
+func newConn(
 	t *testing.T,
 	addr string,
 	certFile string,
 	keyFile string,
-) pb.LogClient {
+) *grpc.ClientConn {
 	clientTLSConfig, err := config.SetupTLSConfig(config.TLSConfig{
 		CertFile: certFile,
 		KeyFile:  keyFile,
@@ -294,5 +1049,5 @@ func newClient(
 		require.NoError(t, clientConn.Close())
 	})
 
-	return pb.NewLogClient(clientConn)
+	return clientConn
 }
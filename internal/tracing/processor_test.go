@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestErrorProcessor(t *testing.T) {
+	sampler, err := NewSampler(SamplingConfig{SampleErrors: true})
+	require.NoError(t, err)
+
+	exporter := &blockingExporter{release: make(chan struct{})}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithSpanProcessor(newErrorProcessor(exporter, false)),
+	)
+
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		for range 3 {
+			_, span := tp.Tracer("test").Start(context.Background(), "log.v1.Log/Consume")
+			span.SetStatus(codes.Error, "boom")
+			span.End()
+		}
+	}()
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("에러 span을 끝내는 고루틴이 익스포터를 기다리면 안 된다")
+	}
+
+	close(exporter.release)
+	require.NoError(t, tp.Shutdown(context.Background()))
+	require.Equal(t, 3, exporter.count(), "종료할 때 남은 span을 내보낸다")
+	require.False(t, exporter.shutdown, "익스포터는 샘플링된 span을 내보내는 프로세서가 닫는다")
+}
+
+// blockingExporter holds every export until release is closed, like a
+// collector that doesn't answer.
+type blockingExporter struct {
+	release chan struct{}
+
+	mu       sync.Mutex
+	spans    []sdktrace.ReadOnlySpan
+	shutdown bool
+}
+
+func (e *blockingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	select {
+	case <-e.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, span := range spans {
+		if !span.SpanContext().IsSampled() {
+			continue
+		}
+		e.spans = append(e.spans, span)
+	}
+	return nil
+}
+
+func (e *blockingExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.shutdown = true
+	return nil
+}
+
+func (e *blockingExporter) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.spans)
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SamplingConfig decides which traces are sampled.
+type SamplingConfig struct {
+	// Ratio is the share of traces sampled, from 0 to 1.
+	Ratio float64
+	// MethodRatios override Ratio for traces starting at a span of the
+	// given name, e.g. "log.v1.Log/Produce" for the Produce RPC.
+	MethodRatios map[string]float64
+	// SampleErrors exports the spans that end in an error even if their
+	// trace wasn't sampled.
+	SampleErrors bool
+}
+
+func (c SamplingConfig) validate() error {
+	if err := validateRatio(c.Ratio); err != nil {
+		return err
+	}
+	for name, ratio := range c.MethodRatios {
+		if err := validateRatio(ratio); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateRatio(ratio float64) error {
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("invalid sampling ratio: %v", ratio)
+	}
+	return nil
+}
+
+var _ sdktrace.Sampler = (*Sampler)(nil)
+
+// Sampler samples traces by a SamplingConfig that can be changed while it's
+// in use. Spans with a parent follow their parent's decision.
+type Sampler struct {
+	config atomic.Pointer[SamplingConfig]
+}
+
+func NewSampler(config SamplingConfig) (*Sampler, error) {
+	s := &Sampler{}
+	if err := s.SetConfig(config); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sampler) Config() SamplingConfig {
+	config := *s.config.Load()
+	config.MethodRatios = maps.Clone(config.MethodRatios)
+	return config
+}
+
+// SetConfig applies config to the spans started from now on.
+func (s *Sampler) SetConfig(config SamplingConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	config.MethodRatios = maps.Clone(config.MethodRatios)
+	s.config.Store(&config)
+	return nil
+}
+
+func (s *Sampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	config := s.config.Load()
+	psc := trace.SpanContextFromContext(p.ParentContext)
+
+	sampled := psc.IsSampled()
+	if !psc.IsValid() {
+		ratio := config.Ratio
+		if r, ok := config.MethodRatios[p.Name]; ok {
+			ratio = r
+		}
+		sampled = sdktrace.TraceIDRatioBased(ratio).ShouldSample(p).Decision == sdktrace.RecordAndSample
+	}
+
+	res := sdktrace.SamplingResult{
+		Decision:   sdktrace.Drop,
+		Tracestate: psc.TraceState(),
+	}
+	switch {
+	case sampled:
+		res.Decision = sdktrace.RecordAndSample
+	case config.SampleErrors:
+		// NOTE - 에러로 끝나는지 알 수 있도록 기록은 해 두고, 내보낼지는 errorProcessor가 정한다.
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s *Sampler) Description() string {
+	config := s.config.Load()
+	return fmt.Sprintf("ProglogSampler{ratio:%v,methods:%d,errors:%t}", config.Ratio, len(config.MethodRatios), config.SampleErrors)
+}
+
+var _ sdktrace.SpanProcessor = errorProcessor{}
+
+// errorProcessor exports the spans that were recorded but not sampled if
+// they ended in an error, see SamplingConfig.SampleErrors, through next, a
+// processor exporting to an exporter that isn't its own, see
+// newErrorProcessor.
+type errorProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+// newErrorProcessor exports error spans to exporter in batches, off the
+// goroutine that ends them, or as they end if sync is set, e.g. in tests.
+// It flushes on Shutdown but leaves shutting exporter down to the processor
+// exporting the sampled spans, which must be registered after it.
+func newErrorProcessor(exporter sdktrace.SpanExporter, sync bool) errorProcessor {
+	exporter = sharedExporter{exporter}
+	if sync {
+		return errorProcessor{next: sdktrace.NewSimpleSpanProcessor(exporter)}
+	}
+	return errorProcessor{next: sdktrace.NewBatchSpanProcessor(exporter)}
+}
+
+func (p errorProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p errorProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() || s.Status().Code != codes.Error {
+		return
+	}
+	// NOTE - SDK 프로세서는 샘플링되지 않은 span을 버리므로 내보내는 span은 샘플링된 것으로 표시한다.
+	p.next.OnEnd(sampledSpan{s})
+}
+
+func (p errorProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p errorProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// sampledSpan is an unsampled span that errorProcessor exports anyway.
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
+
+// sharedExporter doesn't shut down the exporter it shares with another
+// processor.
+type sharedExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (sharedExporter) Shutdown(context.Context) error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/zrma/proglog/internal/tracing"
+)
+
+func TestSampler(t *testing.T) {
+	sampler, err := tracing.NewSampler(tracing.SamplingConfig{
+		Ratio:        0,
+		MethodRatios: map[string]float64{"log.v1.Log/Produce": 1},
+	})
+	require.NoError(t, err)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := tracing.New(context.Background(), tracing.Config{
+		Sampler:   sampler,
+		Exporters: []sdktrace.SpanExporter{exporter},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+	})
+	tracer := tp.Tracer("test")
+
+	startSpan := func(name string, err error) {
+		ctx, span := tracer.Start(context.Background(), name)
+		_, child := tracer.Start(ctx, "child")
+		if err != nil {
+			child.RecordError(err)
+			child.SetStatus(codes.Error, err.Error())
+		}
+		child.End()
+		span.End()
+	}
+	spanNames := func() []string {
+		defer exporter.Reset()
+
+		var names []string
+		for _, span := range exporter.GetSpans() {
+			names = append(names, span.Name)
+		}
+		return names
+	}
+
+	startSpan("log.v1.Log/Produce", nil)
+	startSpan("log.v1.Log/Consume", nil)
+	require.Equal(t, []string{"child", "log.v1.Log/Produce"}, spanNames(), "메서드별 비율이 기본 비율보다 우선한다")
+
+	startSpan("log.v1.Log/Consume", errors.New("boom"))
+	require.Empty(t, spanNames(), "에러 샘플링이 꺼져 있으면 에러도 버린다")
+
+	require.NoError(t, sampler.SetConfig(tracing.SamplingConfig{SampleErrors: true}))
+	startSpan("log.v1.Log/Produce", nil)
+	startSpan("log.v1.Log/Consume", errors.New("boom"))
+	require.Equal(t, []string{"child"}, spanNames(), "샘플링되지 않은 트레이스에서도 에러로 끝난 span은 내보낸다")
+
+	require.Error(t, sampler.SetConfig(tracing.SamplingConfig{Ratio: -1}))
+	require.Error(t, sampler.SetConfig(tracing.SamplingConfig{MethodRatios: map[string]float64{"x": 1.5}}))
+	require.Equal(t, tracing.SamplingConfig{SampleErrors: true}, sampler.Config(), "잘못된 설정은 적용하지 않는다")
+}
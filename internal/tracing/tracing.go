@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/zrma/proglog/internal/pb"
+)
+
+const defaultServiceName = "proglog"
+
+type Config struct {
+	// ServiceName names the service the spans come from. It defaults to
+	// proglog.
+	ServiceName string
+	// Sampler decides which traces are recorded. It defaults to sampling
+	// every trace a parent didn't decide on, see ParseSampler. A *Sampler
+	// can also export the unsampled spans that end in an error.
+	Sampler sdktrace.Sampler
+	// OTLPEndpoint, if set, is the host:port of an OTLP collector spans are
+	// exported to over gRPC, with OTLPTLSConfig or in plain text.
+	OTLPEndpoint  string
+	OTLPTLSConfig *tls.Config
+	// Exporters receive every span synchronously as it ends, e.g. a
+	// tracetest.InMemoryExporter in tests.
+	Exporters []sdktrace.SpanExporter
+}
+
+// New creates a tracer provider from config and installs it, along with the
+// W3C trace context propagator, as the global one. Shut it down to flush
+// the spans still buffered for export.
+func New(ctx context.Context, config Config) (*sdktrace.TracerProvider, error) {
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	if config.Sampler == nil {
+		config.Sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(config.Sampler),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", config.ServiceName),
+		)),
+	}
+	if config.OTLPEndpoint != "" {
+		clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.OTLPEndpoint)}
+		if config.OTLPTLSConfig != nil {
+			clientOpts = append(clientOpts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(config.OTLPTLSConfig)))
+		} else {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		// NOTE - 종료할 때 에러 span을 먼저 내보낸 뒤 익스포터를 닫도록 errorProcessor를 먼저 등록한다.
+		opts = append(opts,
+			sdktrace.WithSpanProcessor(newErrorProcessor(exporter, false)),
+			sdktrace.WithBatcher(exporter),
+		)
+	}
+	for _, exporter := range config.Exporters {
+		opts = append(opts,
+			sdktrace.WithSpanProcessor(newErrorProcessor(exporter, true)),
+			sdktrace.WithSyncer(exporter),
+		)
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return tp, nil
+}
+
+// ParseSampler returns the sampler named like the OTEL_TRACES_SAMPLER
+// environment variable, with arg as OTEL_TRACES_SAMPLER_ARG: always_on,
+// always_off and traceidratio, optionally prefixed with parentbased_ to
+// follow the parent's decision. An empty name is parentbased_always_on.
+func ParseSampler(name, arg string) (sdktrace.Sampler, error) {
+	switch name {
+	case "":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		ratio, err := parseRatio(arg)
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case "parentbased_always_on", "parentbased_always_off", "parentbased_traceidratio":
+		root, err := ParseSampler(name[len("parentbased_"):], arg)
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(root), nil
+	default:
+		return nil, fmt.Errorf("unknown sampler: %q", name)
+	}
+}
+
+func parseRatio(arg string) (float64, error) {
+	if arg == "" {
+		return 1, nil
+	}
+	ratio, err := strconv.ParseFloat(arg, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("invalid sampling ratio: %q", arg)
+	}
+	return ratio, nil
+}
+
+// Inject stores the trace context of ctx in the record's headers, so whoever
+// reads the record can relate their spans to the producer's.
+func Inject(ctx context.Context, record *pb.Record) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	if record.Headers == nil {
+		record.Headers = make(map[string][]byte)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(record.Headers))
+}
+
+// Extract returns ctx carrying the trace context stored in the record's
+// headers, if any, as its remote parent.
+func Extract(ctx context.Context, record *pb.Record) context.Context {
+	if len(record.GetHeaders()) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier(record.GetHeaders()))
+}
+
+// Link returns a link to the span that produced the record; it's invalid if
+// the record carries no trace context.
+func Link(record *pb.Record) trace.Link {
+	return trace.Link{
+		SpanContext: trace.SpanContextFromContext(Extract(context.Background(), record)),
+	}
+}
+
+var _ propagation.TextMapCarrier = headerCarrier(nil)
+
+// headerCarrier lets propagators read and write record headers.
+type headerCarrier map[string][]byte
+
+func (c headerCarrier) Get(key string) string {
+	return string(c[key])
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = []byte(value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,70 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/zrma/proglog/internal/pb"
+	"github.com/zrma/proglog/internal/tracing"
+)
+
+func TestParseSampler(t *testing.T) {
+	for _, tc := range []struct {
+		name, arg string
+		want      string
+	}{
+		{"", "", sdktrace.ParentBased(sdktrace.AlwaysSample()).Description()},
+		{"always_on", "", sdktrace.AlwaysSample().Description()},
+		{"always_off", "", sdktrace.NeverSample().Description()},
+		{"traceidratio", "0.25", sdktrace.TraceIDRatioBased(0.25).Description()},
+		{"parentbased_traceidratio", "0.5", sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.5)).Description()},
+	} {
+		sampler, err := tracing.ParseSampler(tc.name, tc.arg)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, sampler.Description())
+	}
+
+	for _, tc := range [][2]string{
+		{"sometimes", ""},
+		{"traceidratio", "1.5"},
+		{"parentbased_traceidratio", "half"},
+	} {
+		_, err := tracing.ParseSampler(tc[0], tc[1])
+		require.Error(t, err, "잘못된 샘플러 설정은 거부한다")
+	}
+}
+
+func TestRecordPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp, err := tracing.New(context.Background(), tracing.Config{
+		Exporters: []sdktrace.SpanExporter{exporter},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+	})
+
+	record := &pb.Record{Value: []byte("hello world")}
+	require.False(t, tracing.Link(record).SpanContext.IsValid(), "트레이스 없이 쓴 레코드에는 링크가 없다")
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "produce")
+	tracing.Inject(ctx, record)
+	span.End()
+
+	require.NotEmpty(t, record.GetHeaders())
+	link := tracing.Link(record)
+	require.True(t, link.SpanContext.IsRemote())
+	require.Equal(t, span.SpanContext().TraceID(), link.SpanContext.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), link.SpanContext.SpanID())
+
+	_, child := tp.Tracer("test").Start(tracing.Extract(context.Background(), record), "apply")
+	child.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, span.SpanContext().SpanID(), spans[1].Parent.SpanID())
+}